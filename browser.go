@@ -0,0 +1,303 @@
+package celeritas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// DefaultBrowserTimeout limits each navigation, wait and action of a
+// BrowserPage whose Browser has no Timeout
+const DefaultBrowserTimeout = 30 * time.Second
+
+// Browser drives a headless browser for end to end tests. The browser is
+// started the first time a page is opened and shared by every page until
+// Close.
+type Browser struct {
+	// BaseURL is put in front of paths opened which are not full URLs, e.g.
+	// the URL of a celeritastest server
+	BaseURL string
+	// Timeout limits each navigation, wait and action,
+	// DefaultBrowserTimeout if 0
+	Timeout time.Duration
+	// Width and Height are the size of the window, 1280x800 if 0
+	Width, Height int
+	// Screenshots is the folder holding the baselines MatchScreenshot
+	// compares pages with
+	Screenshots string
+	// Threshold is the fraction of pixels which may differ from a baseline
+	// before MatchScreenshot fails
+	Threshold float64
+	// Update makes MatchScreenshot replace baselines instead of comparing
+	// with them; UPDATE_SCREENSHOTS=true sets it
+	Update bool
+
+	mu      sync.Mutex
+	browser *rod.Browser
+}
+
+// NewBrowser returns a Browser keeping its baselines in the screenshots
+// folder
+func NewBrowser(screenshots string) *Browser {
+	return &Browser{
+		Screenshots: screenshots,
+		Update:      strings.ToLower(os.Getenv("UPDATE_SCREENSHOTS")) == "true",
+	}
+}
+
+// Browser returns the browser shared by the application's browser tests,
+// which keeps its baselines in the screenshots folder
+func (c *Celeritas) Browser() *Browser {
+	c.browserOnce.Do(func() {
+		c.browser = NewBrowser(filepath.Join(c.RootPath, "screenshots"))
+	})
+	return c.browser
+}
+
+func (b *Browser) timeout() time.Duration {
+	if b.Timeout > 0 {
+		return b.Timeout
+	}
+	return DefaultBrowserTimeout
+}
+
+// connect starts the browser if it is not running
+func (b *Browser) connect() (*rod.Browser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.browser != nil {
+		return b.browser, nil
+	}
+
+	browser := rod.New()
+	if err := browser.Connect(); err != nil {
+		return nil, fmt.Errorf("starting browser: %w", err)
+	}
+	if err := browser.IgnoreCertErrors(true); err != nil {
+		_ = browser.Close()
+		return nil, err
+	}
+
+	b.browser = browser
+	return browser, nil
+}
+
+// Close stops the browser, closing every page opened in it
+func (b *Browser) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.browser == nil {
+		return nil
+	}
+
+	err := b.browser.Close()
+	b.browser = nil
+	return err
+}
+
+// BrowserPage is a page opened in a Browser. Its methods return errors
+// instead of panicking like rod's Must methods.
+type BrowserPage struct {
+	*rod.Page
+	browser *Browser
+
+	mu            sync.Mutex
+	consoleErrors []string
+}
+
+// Open opens url, or a path under BaseURL, in a new page and waits for it
+// to load. Console errors and uncaught exceptions on the page are recorded
+// from the start.
+func (b *Browser) Open(url string) (*BrowserPage, error) {
+	browser, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.Contains(url, "://") {
+		url = strings.TrimSuffix(b.BaseURL, "/") + "/" + strings.TrimPrefix(url, "/")
+	}
+
+	page, err := browser.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &BrowserPage{Page: page, browser: b}
+
+	width, height := b.Width, b.Height
+	if width == 0 || height == 0 {
+		width, height = 1280, 800
+	}
+	err = page.SetViewport(&proto.EmulationSetDeviceMetricsOverride{
+		Width:             width,
+		Height:            height,
+		DeviceScaleFactor: 1,
+	})
+	if err != nil {
+		_ = page.Close()
+		return nil, err
+	}
+
+	go page.EachEvent(func(e *proto.RuntimeConsoleAPICalled) {
+		if e.Type == proto.RuntimeConsoleAPICalledTypeError {
+			p.recordError(consoleMessage(e.Args))
+		}
+	}, func(e *proto.RuntimeExceptionThrown) {
+		p.recordError(e.ExceptionDetails.Text + " " + description(e.ExceptionDetails.Exception))
+	})()
+
+	if err := p.Navigate(url); err != nil {
+		_ = page.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Navigate goes to url and waits for the page to load
+func (p *BrowserPage) Navigate(url string) error {
+	page := p.Timeout(p.browser.timeout())
+	if err := page.Navigate(url); err != nil {
+		return fmt.Errorf("opening %s: %w", url, err)
+	}
+	return page.WaitLoad()
+}
+
+func (p *BrowserPage) recordError(message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.consoleErrors = append(p.consoleErrors, strings.TrimSpace(message))
+}
+
+// ConsoleErrors returns the errors logged to the console and the uncaught
+// exceptions on the page so far
+func (p *BrowserPage) ConsoleErrors() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.consoleErrors...)
+}
+
+func consoleMessage(args []*proto.RuntimeRemoteObject) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = description(arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+func description(obj *proto.RuntimeRemoteObject) string {
+	switch {
+	case obj == nil:
+		return ""
+	case obj.Description != "":
+		return obj.Description
+	default:
+		return fmt.Sprint(obj.Value.Val())
+	}
+}
+
+// WaitFor waits until an element matching selector is visible and returns it
+func (p *BrowserPage) WaitFor(selector string) (*rod.Element, error) {
+	el, err := p.Timeout(p.browser.timeout()).Element(selector)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for %s: %w", selector, err)
+	}
+	if err := el.WaitVisible(); err != nil {
+		return nil, fmt.Errorf("waiting for %s: %w", selector, err)
+	}
+	return el, nil
+}
+
+// Text returns the text of the element matching selector
+func (p *BrowserPage) Text(selector string) (string, error) {
+	el, err := p.WaitFor(selector)
+	if err != nil {
+		return "", err
+	}
+	return el.Text()
+}
+
+// Click clicks the element matching selector
+func (p *BrowserPage) Click(selector string) error {
+	el, err := p.WaitFor(selector)
+	if err != nil {
+		return err
+	}
+	return el.Click(proto.InputMouseButtonLeft, 1)
+}
+
+// Fill sets the value of the field matching selector, replacing what it
+// held. Checkboxes are checked by true, on or their own value.
+func (p *BrowserPage) Fill(selector, value string) error {
+	el, err := p.WaitFor(selector)
+	if err != nil {
+		return err
+	}
+
+	_, err = el.Eval(`(v) => {
+		if (this.type === 'checkbox' || this.type === 'radio') {
+			this.checked = ['true', 'on', this.value].includes(v)
+		} else {
+			this.value = v
+		}
+		this.dispatchEvent(new Event('input', { bubbles: true }))
+		this.dispatchEvent(new Event('change', { bubbles: true }))
+	}`, value)
+	if err != nil {
+		return fmt.Errorf("filling %s: %w", selector, err)
+	}
+	return nil
+}
+
+// FillForm sets the fields of the form matching selector, keyed by name
+func (p *BrowserPage) FillForm(selector string, values map[string]string) error {
+	for name, value := range values {
+		if err := p.Fill(fmt.Sprintf(`%s [name="%s"]`, selector, name), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Submit submits the form matching selector, as if its submit button was
+// pressed, and waits for the page it leads to to load
+func (p *BrowserPage) Submit(selector string) error {
+	form, err := p.WaitFor(selector)
+	if err != nil {
+		return err
+	}
+
+	page := p.Timeout(p.browser.timeout())
+	wait := page.WaitNavigation(proto.PageLifecycleEventNameLoad)
+
+	_, err = form.Eval(`() => this.requestSubmit ? this.requestSubmit() : this.submit()`)
+	if err != nil {
+		return fmt.Errorf("submitting %s: %w", selector, err)
+	}
+
+	wait()
+	if err := page.GetContext().Err(); errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("submitting %s: %w", selector, err)
+	}
+	return nil
+}
+
+// Screenshot captures the visible part of the page as a PNG
+func (p *BrowserPage) Screenshot() ([]byte, error) {
+	return p.Timeout(p.browser.timeout()).Screenshot(false, &proto.PageCaptureScreenshot{
+		Format: proto.PageCaptureScreenshotFormatPng,
+	})
+}
@@ -1,28 +1,75 @@
 package cache
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
 )
 
+// BadgerCache stores values in Badger, encoded with Codec (gob if nil)
 type BadgerCache struct {
 	Conn   *badger.DB
 	Prefix string
+	Codec  Codec
 }
 
+// Has reports whether str is cached, without decoding its value, so that
+// counters, which are not encoded with Codec, are found too
 func (b *BadgerCache) Has(str string) (bool, error) {
-	if _, err := b.Get(str); err != nil {
+	err := b.Conn.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(b.key(str))
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
 		return false, nil
 	}
+	if err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
 func (b *BadgerCache) Get(str string) (any, error) {
+	var item any
+	if err := b.Scan(str, &item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Scan decodes the value cached under str into dst, which must be a pointer
+func (b *BadgerCache) Scan(str string, dst any) error {
+	fromCache, err := b.getRaw(str)
+	if err != nil {
+		return err
+	}
+
+	return codecOrDefault(b.Codec).Unmarshal(fromCache, dst)
+}
+
+func (b *BadgerCache) Set(str string, value any, ttl ...int) error {
+	encoded, err := codecOrDefault(b.Codec).Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return b.setRaw(str, encoded, ttl...)
+}
+
+// Remember returns the value cached under str, or calls loader and caches
+// what it returns for ttl seconds if str is not cached
+func (b *BadgerCache) Remember(str string, ttl int, loader func() (any, error), opts ...RememberOptions) (any, error) {
+	return remember(b, codecOrDefault(b.Codec), str, ttl, loader, opts)
+}
+
+func (b *BadgerCache) getRaw(str string) ([]byte, error) {
 	var fromCache []byte
 
 	if err := b.Conn.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(str))
+		item, err := txn.Get(b.key(str))
 		if err != nil {
 			return err
 		}
@@ -34,50 +81,24 @@ func (b *BadgerCache) Get(str string) (any, error) {
 
 		return err
 	}); err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
 		return nil, err
 	}
 
-	decoded, err := decode(string(fromCache))
-	if err != nil {
-		return nil, err
-	}
-
-	item := decoded[str]
-
-	return item, nil
+	return fromCache, nil
 }
 
-func (b *BadgerCache) Set(str string, value any, ttl ...int) error {
-	entry := Entry{}
-	entry[str] = value
-	encoded, err := encode(entry)
-	if err != nil {
-		return err
-	}
-
-	if len(ttl) > 0 {
-		if err := b.Conn.Update(func(txn *badger.Txn) error {
-			e := badger.NewEntry([]byte(str), encoded).WithTTL(time.Second * time.Duration(ttl[0]))
-			err = txn.SetEntry(e)
-			return err
-		}); err != nil {
-			return err
-		}
-	} else {
-		if err := b.Conn.Update(func(txn *badger.Txn) error {
-			e := badger.NewEntry([]byte(str), encoded)
-			err = txn.SetEntry(e)
-			return err
-		}); err != nil {
-			return err
-		}
-	}
-	return nil
+func (b *BadgerCache) setRaw(str string, encoded []byte, ttl ...int) error {
+	return b.Conn.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(b.entry(str, encoded, ttl...))
+	})
 }
 
 func (b *BadgerCache) Forget(str string) error {
 	return b.Conn.Update(func(txn *badger.Txn) error {
-		return txn.Delete([]byte(str))
+		return txn.Delete(b.key(str))
 	})
 }
 
@@ -89,6 +110,14 @@ func (b *BadgerCache) Empty() error {
 	return b.emptyByMatch("")
 }
 
+// key namespaces str with the prefix, if there is one
+func (b *BadgerCache) key(str string) []byte {
+	if b.Prefix == "" {
+		return []byte(str)
+	}
+	return []byte(b.Prefix + ":" + str)
+}
+
 func (b *BadgerCache) emptyByMatch(str string) error {
 
 	deleteKeys := func(keysForDelete [][]byte) error {
@@ -114,7 +143,8 @@ func (b *BadgerCache) emptyByMatch(str string) error {
 		keysForDelete := make([][]byte, 0, collectSize)
 		keysCollected := 0
 
-		for it.Seek([]byte(str)); it.ValidForPrefix([]byte(str)); it.Next() {
+		prefix := b.key(str)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
 			key := it.Item().KeyCopy(nil)
 			keysForDelete = append(keysForDelete, key)
 			keysCollected++
@@ -135,3 +165,385 @@ func (b *BadgerCache) emptyByMatch(str string) error {
 		return nil
 	})
 }
+
+// update runs fn in a read-write transaction, retrying if it conflicts with
+// a concurrent one
+func (b *BadgerCache) update(fn func(txn *badger.Txn) error) error {
+	for {
+		err := b.Conn.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+}
+
+func (b *BadgerCache) entry(str string, value []byte, ttl ...int) *badger.Entry {
+	e := badger.NewEntry(b.key(str), value)
+	if len(ttl) > 0 {
+		e = e.WithTTL(time.Second * time.Duration(ttl[0]))
+	}
+	return e
+}
+
+// SetIfNotExists stores value under str only if str is not already cached,
+// and reports whether it did
+func (b *BadgerCache) SetIfNotExists(str string, value any, ttl ...int) (bool, error) {
+	encoded, err := codecOrDefault(b.Codec).Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	var set bool
+	err = b.update(func(txn *badger.Txn) error {
+		set = false
+
+		_, err := txn.Get(b.key(str))
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		set = true
+		return txn.SetEntry(b.entry(str, encoded, ttl...))
+	})
+	return set, err
+}
+
+// GetMany fetches several keys in one transaction. Keys which are not cached
+// are left out of the result.
+func (b *BadgerCache) GetMany(strs ...string) (map[string]any, error) {
+	items := make(map[string]any, len(strs))
+
+	err := b.Conn.View(func(txn *badger.Txn) error {
+		for _, str := range strs {
+			item, err := txn.Get(b.key(str))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			var value any
+			if err := item.Value(func(val []byte) error {
+				return codecOrDefault(b.Codec).Unmarshal(val, &value)
+			}); err != nil {
+				return err
+			}
+			items[str] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// SetMany stores several values in a single transaction
+func (b *BadgerCache) SetMany(items map[string]any, ttl ...int) error {
+	entries := make([]*badger.Entry, 0, len(items))
+	for str, value := range items {
+		encoded, err := codecOrDefault(b.Codec).Marshal(value)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, b.entry(str, encoded, ttl...))
+	}
+
+	return b.update(func(txn *badger.Txn) error {
+		for _, e := range entries {
+			if err := txn.SetEntry(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Increment atomically adds by to the counter under str, starting from 0,
+// and returns the new value. The counter keeps any expiry it already had.
+func (b *BadgerCache) Increment(str string, by int64) (int64, error) {
+	return b.IncrementExpiring(str, by, 0)
+}
+
+// IncrementExpiring atomically adds by to the counter under str, starting
+// from 0, and sets it to expire ttl seconds from now unless it already
+// expires
+func (b *BadgerCache) IncrementExpiring(str string, by int64, ttl int) (int64, error) {
+	var n int64
+
+	err := b.update(func(txn *badger.Txn) error {
+		n = 0
+		var expiresAt uint64
+
+		item, err := txn.Get(b.key(str))
+		switch {
+		case err == nil:
+			expiresAt = item.ExpiresAt()
+			if err := item.Value(func(val []byte) error {
+				n, err = strconv.ParseInt(string(val), 10, 64)
+				return err
+			}); err != nil {
+				return fmt.Errorf("cache: %s is not a counter: %w", str, err)
+			}
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		n += by
+		e := badger.NewEntry(b.key(str), []byte(strconv.FormatInt(n, 10)))
+		if expiresAt > 0 {
+			e.ExpiresAt = expiresAt
+		} else if ttl > 0 {
+			e = e.WithTTL(time.Second * time.Duration(ttl))
+		}
+		return txn.SetEntry(e)
+	})
+
+	return n, err
+}
+
+// Decrement atomically subtracts by from the counter under str
+func (b *BadgerCache) Decrement(str string, by int64) (int64, error) {
+	return b.Increment(str, -by)
+}
+
+// SetWithTags stores value under str and records it against each tag, so
+// that it can be removed along with everything else sharing a tag by
+// FlushTags
+func (b *BadgerCache) SetWithTags(str string, value any, tags []string, ttl ...int) error {
+	encoded, err := codecOrDefault(b.Codec).Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return b.update(func(txn *badger.Txn) error {
+		if err := txn.SetEntry(b.entry(str, encoded, ttl...)); err != nil {
+			return err
+		}
+
+		for _, tag := range tags {
+			if err := txn.SetEntry(b.entry(badgerTagKey(tag)+str, nil, ttl...)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FlushTags removes every key stored with any of tags
+func (b *BadgerCache) FlushTags(tags ...string) error {
+	for _, tag := range tags {
+		prefix := b.key(badgerTagKey(tag))
+
+		var keys [][]byte
+		if err := b.Conn.View(func(txn *badger.Txn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			it := txn.NewIterator(opts)
+			defer it.Close()
+
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				keys = append(keys, it.Item().KeyCopy(nil))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if err := b.update(func(txn *badger.Txn) error {
+			for _, key := range keys {
+				if err := txn.Delete(b.key(string(key[len(prefix):]))); err != nil {
+					return err
+				}
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// badgerTagKey is the prefix of the markers recording which keys have tag
+func badgerTagKey(tag string) string {
+	return "tag\x00" + tag + "\x00"
+}
+
+// TTL returns how long str has left before it expires, or 0 if it never
+// expires
+func (b *BadgerCache) TTL(str string) (time.Duration, error) {
+	var expiresAt uint64
+
+	err := b.Conn.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(b.key(str))
+		if err != nil {
+			return err
+		}
+		expiresAt = item.ExpiresAt()
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return 0, fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
+		return 0, err
+	}
+
+	if expiresAt == 0 {
+		return 0, nil
+	}
+	return time.Until(time.Unix(int64(expiresAt), 0)), nil
+}
+
+// Touch sets str to expire ttl seconds from now, or never if ttl is 0
+func (b *BadgerCache) Touch(str string, ttl int) error {
+	err := b.update(func(txn *badger.Txn) error {
+		item, err := txn.Get(b.key(str))
+		if err != nil {
+			return err
+		}
+
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		e := badger.NewEntry(b.key(str), value)
+		if ttl > 0 {
+			e = e.WithTTL(time.Second * time.Duration(ttl))
+		}
+		return txn.SetEntry(e)
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return err
+}
+
+// AcquireLock takes the lock called name for ttl, or returns ErrLockHeld if
+// another owner has it. Badger expires keys to the second, so ttl is
+// rounded up to whole seconds.
+func (b *BadgerCache) AcquireLock(name string, ttl time.Duration) (*Lock, error) {
+	key := lockPrefix + name
+	token := newLockToken()
+
+	var fence int64
+	err := b.update(func(txn *badger.Txn) error {
+		fence = 0
+
+		if _, err := txn.Get(b.key(key)); err == nil {
+			return ErrLockHeld
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		item, err := txn.Get(b.key(key + ":fence"))
+		switch {
+		case err == nil:
+			if err := item.Value(func(val []byte) error {
+				fence, err = strconv.ParseInt(string(val), 10, 64)
+				return err
+			}); err != nil {
+				return err
+			}
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		fence++
+		if err := txn.Set(b.key(key+":fence"), []byte(strconv.FormatInt(fence, 10))); err != nil {
+			return err
+		}
+		return txn.SetEntry(b.entry(key, []byte(token), CeilSeconds(ttl)))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lock{Name: name, Token: token, Fence: fence, store: b}, nil
+}
+
+func (b *BadgerCache) renewLock(name, token string, ttl time.Duration) error {
+	return b.holdingLock(name, token, func(txn *badger.Txn) error {
+		return txn.SetEntry(b.entry(lockPrefix+name, []byte(token), CeilSeconds(ttl)))
+	})
+}
+
+func (b *BadgerCache) releaseLock(name, token string) error {
+	return b.holdingLock(name, token, func(txn *badger.Txn) error {
+		return txn.Delete(b.key(lockPrefix + name))
+	})
+}
+
+// holdingLock runs fn only if the lock called name is still held with
+// token
+func (b *BadgerCache) holdingLock(name, token string, fn func(txn *badger.Txn) error) error {
+	return b.update(func(txn *badger.Txn) error {
+		item, err := txn.Get(b.key(lockPrefix + name))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrLockLost
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := item.Value(func(val []byte) error {
+			if string(val) != token {
+				return ErrLockLost
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		return fn(txn)
+	})
+}
+
+// TakeToken takes a token from the bucket under key, reporting whether
+// there was one to take
+func (b *BadgerCache) TakeToken(key string, bucket Bucket) (BucketState, error) {
+	key = bucketPrefix + key
+	now := time.Now().UnixMilli()
+
+	var allowed bool
+	var tat int64
+	err := b.update(func(txn *badger.Txn) error {
+		tat = now
+
+		item, err := txn.Get(b.key(key))
+		switch {
+		case err == nil:
+			if err := item.Value(func(val []byte) error {
+				tat, err = strconv.ParseInt(string(val), 10, 64)
+				return err
+			}); err != nil {
+				return err
+			}
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		allowed, tat = bucket.take(tat, now)
+		if !allowed {
+			return nil
+		}
+
+		ttl := time.Duration(tat-now) * time.Millisecond
+		return txn.SetEntry(b.entry(key, []byte(strconv.FormatInt(tat, 10)), CeilSeconds(ttl)))
+	})
+	if err != nil {
+		return BucketState{}, err
+	}
+
+	return bucket.state(allowed, tat, now), nil
+}
@@ -136,3 +136,33 @@ func TestBadgerCache_EmptyByMatch(t *testing.T) {
 		t.Error("\"three\" not found in cache but it should be there")
 	}
 }
+
+func TestBadgerCache_Prefix(t *testing.T) {
+	other := BadgerCache{Conn: testBadgerCache.Conn, Prefix: "other-app"}
+
+	if err := testBadgerCache.Set("shared", "ours"); err != nil {
+		t.Error(err)
+	}
+
+	if err := other.Set("shared", "theirs"); err != nil {
+		t.Error(err)
+	}
+
+	if x, _ := other.Get("shared"); x != "theirs" {
+		t.Errorf("expected prefixes to keep values apart, got %v", x)
+	}
+
+	if err := testBadgerCache.Empty(); err != nil {
+		t.Error(err)
+	}
+
+	if inCache, _ := testBadgerCache.Has("shared"); inCache {
+		t.Error("\"shared\" found in cache but it should have been emptied")
+	}
+
+	if inCache, _ := other.Has("shared"); !inCache {
+		t.Error("Empty removed a key outside its prefix")
+	}
+
+	_ = other.Forget("shared")
+}
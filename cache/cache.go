@@ -3,23 +3,51 @@ package cache
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
 
+// ErrNotFound is returned when a key is not in the cache
+var ErrNotFound = errors.New("cache: key not found")
+
+// Cache is implemented by every cache driver. Counters changed with
+// Increment and Decrement are stored as plain numbers rather than with the
+// cache's codec; read one with Increment(key, 0).
 type Cache interface {
 	Has(string) (bool, error)
 	Get(string) (any, error)
+	Scan(string, any) error
 	Set(string, any, ...int) error
+	Remember(string, int, func() (any, error), ...RememberOptions) (any, error)
+	SetIfNotExists(string, any, ...int) (bool, error)
+	GetMany(...string) (map[string]any, error)
+	SetMany(map[string]any, ...int) error
+	Increment(string, int64) (int64, error)
+	Decrement(string, int64) (int64, error)
+	SetWithTags(string, any, []string, ...int) error
+	FlushTags(...string) error
+	TTL(string) (time.Duration, error)
+	Touch(string, int) error
 	Forget(string) error
 	EmptyByMatch(string) error
 	Empty() error
 }
 
+// GetAs returns the value cached under key decoded as a T
+func GetAs[T any](c Cache, key string) (T, error) {
+	var value T
+	err := c.Scan(key, &value)
+	return value, err
+}
+
+// RedisCache stores values in Redis, encoded with Codec (gob if nil)
 type RedisCache struct {
 	Conn   *redis.Pool
 	Prefix string
+	Codec  Codec
 }
 
 type Entry map[string]any
@@ -60,37 +88,57 @@ func (c *RedisCache) Has(str string) (bool, error) {
 }
 
 func (c *RedisCache) Get(str string) (any, error) {
-	key := fmt.Sprintf("%s:%s", c.Prefix, str)
-	conn := c.Conn.Get()
-	defer conn.Close()
+	var item any
+	if err := c.Scan(str, &item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
 
-	cacheEntry, err := redis.Bytes(conn.Do("GET", key))
+// Scan decodes the value cached under str into dst, which must be a pointer
+func (c *RedisCache) Scan(str string, dst any) error {
+	cacheEntry, err := c.getRaw(str)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	decoded, err := decode(string(cacheEntry))
+	return codecOrDefault(c.Codec).Unmarshal(cacheEntry, dst)
+}
+
+func (c *RedisCache) Set(str string, value any, ttl ...int) error {
+	encoded, err := codecOrDefault(c.Codec).Marshal(value)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	item := decoded[key]
+	return c.setRaw(str, encoded, ttl...)
+}
 
-	return item, nil
+// Remember returns the value cached under str, or calls loader and caches
+// what it returns for ttl seconds if str is not cached
+func (c *RedisCache) Remember(str string, ttl int, loader func() (any, error), opts ...RememberOptions) (any, error) {
+	return remember(c, codecOrDefault(c.Codec), str, ttl, loader, opts)
 }
 
-func (c *RedisCache) Set(str string, value any, ttl ...int) error {
+func (c *RedisCache) getRaw(str string) ([]byte, error) {
 	key := fmt.Sprintf("%s:%s", c.Prefix, str)
 	conn := c.Conn.Get()
 	defer conn.Close()
 
-	entry := Entry{}
-	entry[key] = value
-
-	encoded, err := encode(entry)
+	cacheEntry, err := redis.Bytes(conn.Do("GET", key))
 	if err != nil {
-		return err
+		if errors.Is(err, redis.ErrNil) {
+			return nil, fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
+		return nil, err
 	}
+	return cacheEntry, nil
+}
+
+func (c *RedisCache) setRaw(str string, encoded []byte, ttl ...int) error {
+	key := fmt.Sprintf("%s:%s", c.Prefix, str)
+	conn := c.Conn.Get()
+	defer conn.Close()
 
 	if len(ttl) > 0 {
 		_, err := conn.Do("SETEX", key, ttl[0], string(encoded))
@@ -185,3 +233,357 @@ func (c *RedisCache) getKeys(pattern string) ([]string, error) {
 
 	return keys, nil
 }
+
+// SetIfNotExists stores value under str only if str is not already cached,
+// and reports whether it did
+func (c *RedisCache) SetIfNotExists(str string, value any, ttl ...int) (bool, error) {
+	key := fmt.Sprintf("%s:%s", c.Prefix, str)
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	encoded, err := codecOrDefault(c.Codec).Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	args := redis.Args{key, string(encoded), "NX"}
+	if len(ttl) > 0 {
+		args = args.Add("EX", ttl[0])
+	}
+
+	reply, err := conn.Do("SET", args...)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// GetMany fetches several keys in one round trip. Keys which are not cached
+// are left out of the result.
+func (c *RedisCache) GetMany(strs ...string) (map[string]any, error) {
+	items := make(map[string]any, len(strs))
+	if len(strs) == 0 {
+		return items, nil
+	}
+
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	args := redis.Args{}
+	for _, str := range strs {
+		args = args.Add(fmt.Sprintf("%s:%s", c.Prefix, str))
+	}
+
+	values, err := redis.ByteSlices(conn.Do("MGET", args...))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+
+		var item any
+		if err := codecOrDefault(c.Codec).Unmarshal(value, &item); err != nil {
+			return nil, err
+		}
+		items[strs[i]] = item
+	}
+
+	return items, nil
+}
+
+// SetMany stores several values in a single transaction
+func (c *RedisCache) SetMany(items map[string]any, ttl ...int) error {
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+
+	for str, value := range items {
+		key := fmt.Sprintf("%s:%s", c.Prefix, str)
+
+		encoded, err := codecOrDefault(c.Codec).Marshal(value)
+		if err != nil {
+			_, _ = conn.Do("DISCARD")
+			return err
+		}
+
+		if len(ttl) > 0 {
+			err = conn.Send("SETEX", key, ttl[0], string(encoded))
+		} else {
+			err = conn.Send("SET", key, string(encoded))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+// Increment atomically adds by to the counter under str, starting from 0,
+// and returns the new value
+func (c *RedisCache) Increment(str string, by int64) (int64, error) {
+	key := fmt.Sprintf("%s:%s", c.Prefix, str)
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	return redis.Int64(conn.Do("INCRBY", key, by))
+}
+
+// incrementExpiringScript adds to a counter and gives it a TTL if it has
+// none, in one step
+var incrementExpiringScript = redis.NewScript(1, `
+local n = redis.call("INCRBY", KEYS[1], ARGV[1])
+if redis.call("TTL", KEYS[1]) == -1 then
+	redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+return n`)
+
+// IncrementExpiring atomically adds by to the counter under str, starting
+// from 0, and sets it to expire ttl seconds from now unless it already
+// expires
+func (c *RedisCache) IncrementExpiring(str string, by int64, ttl int) (int64, error) {
+	if ttl <= 0 {
+		return c.Increment(str, by)
+	}
+
+	key := fmt.Sprintf("%s:%s", c.Prefix, str)
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	return redis.Int64(incrementExpiringScript.Do(conn, key, by, ttl))
+}
+
+// Decrement atomically subtracts by from the counter under str
+func (c *RedisCache) Decrement(str string, by int64) (int64, error) {
+	return c.Increment(str, -by)
+}
+
+// SetWithTags stores value under str and records it against each tag, so
+// that it can be removed along with everything else sharing a tag by
+// FlushTags
+func (c *RedisCache) SetWithTags(str string, value any, tags []string, ttl ...int) error {
+	if err := c.Set(str, value, ttl...); err != nil {
+		return err
+	}
+
+	expires := 0
+	if len(ttl) > 0 {
+		expires = ttl[0]
+	}
+
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	for _, tag := range tags {
+		if err := tagScript.Send(conn, c.tagKey(tag), str, expires); err != nil {
+			return err
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+
+	for range tags {
+		if _, err := conn.Receive(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagScript adds a member to a tag's set, which is kept for as long as the
+// longest lived of its members: never, once a member without a TTL is
+// added
+var tagScript = redis.NewScript(1, `
+local existed = redis.call("EXISTS", KEYS[1])
+local current = redis.call("TTL", KEYS[1])
+redis.call("SADD", KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl <= 0 then
+	redis.call("PERSIST", KEYS[1])
+elseif existed == 0 or (current >= 0 and current < ttl) then
+	redis.call("EXPIRE", KEYS[1], ttl)
+end
+return 1`)
+
+// FlushTags removes every key stored with any of tags
+func (c *RedisCache) FlushTags(tags ...string) error {
+	_, err := c.flushTags(tags...)
+	return err
+}
+
+// flushTags removes the keys stored with tags and returns them
+func (c *RedisCache) flushTags(tags ...string) ([]string, error) {
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	var flushed []string
+	for _, tag := range tags {
+		members, err := redis.Strings(conn.Do("SMEMBERS", c.tagKey(tag)))
+		if err != nil {
+			return flushed, err
+		}
+
+		args := redis.Args{c.tagKey(tag)}
+		for _, member := range members {
+			args = args.Add(fmt.Sprintf("%s:%s", c.Prefix, member))
+		}
+
+		if _, err := conn.Do("DEL", args...); err != nil {
+			return flushed, err
+		}
+		flushed = append(flushed, members...)
+	}
+
+	return flushed, nil
+}
+
+func (c *RedisCache) tagKey(tag string) string {
+	return fmt.Sprintf("%s:tag:%s", c.Prefix, tag)
+}
+
+// TTL returns how long str has left before it expires, or 0 if it never
+// expires
+func (c *RedisCache) TTL(str string) (time.Duration, error) {
+	key := fmt.Sprintf("%s:%s", c.Prefix, str)
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	ms, err := redis.Int64(conn.Do("PTTL", key))
+	if err != nil {
+		return 0, err
+	}
+
+	switch ms {
+	case -2:
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, str)
+	case -1:
+		return 0, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Touch sets str to expire ttl seconds from now, or never if ttl is 0
+func (c *RedisCache) Touch(str string, ttl int) error {
+	key := fmt.Sprintf("%s:%s", c.Prefix, str)
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	exists, err := redis.Bool(conn.Do("EXISTS", key))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, str)
+	}
+
+	if ttl > 0 {
+		_, err = conn.Do("EXPIRE", key, ttl)
+	} else {
+		_, err = conn.Do("PERSIST", key)
+	}
+	return err
+}
+
+var (
+	acquireLockScript = redis.NewScript(2, `
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0`)
+
+	renewLockScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	releaseLockScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// AcquireLock takes the lock called name for ttl, or returns ErrLockHeld if
+// another owner has it
+func (c *RedisCache) AcquireLock(name string, ttl time.Duration) (*Lock, error) {
+	key := fmt.Sprintf("%s:%s", c.Prefix, lockPrefix+name)
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	token := newLockToken()
+	fence, err := redis.Int64(acquireLockScript.Do(conn, key, key+":fence", token, ttl.Milliseconds()))
+	if err != nil {
+		return nil, err
+	}
+	if fence == 0 {
+		return nil, ErrLockHeld
+	}
+
+	return &Lock{Name: name, Token: token, Fence: fence, store: c}, nil
+}
+
+func (c *RedisCache) renewLock(name, token string, ttl time.Duration) error {
+	return c.lockScript(renewLockScript, name, token, ttl.Milliseconds())
+}
+
+func (c *RedisCache) releaseLock(name, token string) error {
+	return c.lockScript(releaseLockScript, name, token)
+}
+
+// lockScript runs a script which changes the lock called name only if it
+// is still held with token
+func (c *RedisCache) lockScript(script *redis.Script, name, token string, args ...any) error {
+	key := fmt.Sprintf("%s:%s", c.Prefix, lockPrefix+name)
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	held, err := redis.Int(script.Do(conn, append([]any{key, token}, args...)...))
+	if err != nil {
+		return err
+	}
+	if held == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+var takeTokenScript = redis.NewScript(1, `
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local next = tat + interval
+if now < next - burst * interval then
+	return {0, tat}
+end
+redis.call("SET", KEYS[1], next, "PX", next - now)
+return {1, next}`)
+
+// TakeToken takes a token from the bucket under key, reporting whether
+// there was one to take
+func (c *RedisCache) TakeToken(key string, b Bucket) (BucketState, error) {
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	now := time.Now().UnixMilli()
+	result, err := redis.Int64s(takeTokenScript.Do(conn,
+		fmt.Sprintf("%s:%s", c.Prefix, bucketPrefix+key), now, b.interval(), b.burst()))
+	if err != nil {
+		return BucketState{}, err
+	}
+
+	return b.state(result[0] == 1, result[1], now), nil
+}
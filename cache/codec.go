@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec converts values to and from the bytes kept in the cache
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// CodecByName returns the codec for CACHE_CODEC: gob (the default), json
+// or msgpack
+func CodecByName(name string) (Codec, error) {
+	switch strings.ToLower(name) {
+	case "", "gob":
+		return GobCodec{}, nil
+	case "json":
+		return JSONCodec{}, nil
+	case "msgpack":
+		return MsgpackCodec{}, nil
+	}
+	return nil, fmt.Errorf("unknown cache codec %q", name)
+}
+
+// GobCodec stores values in a gob encoded Entry, as earlier versions of the
+// cache did. The concrete types of values are registered with gob as they
+// are stored and read back, so structs round-trip without calling
+// gob.Register first.
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	if v != nil {
+		register(reflect.TypeOf(v))
+	}
+	return encode(Entry{"": v})
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	dst := reflect.ValueOf(v)
+	if dst.Kind() != reflect.Pointer || dst.IsNil() {
+		return fmt.Errorf("cache: cannot decode into %T", v)
+	}
+	dst = dst.Elem()
+
+	if dst.Kind() != reflect.Interface {
+		register(dst.Type())
+	}
+
+	entry, err := decode(string(data))
+	if err != nil {
+		return err
+	}
+
+	// older entries are keyed by the cache key rather than ""
+	var value any
+	for _, v := range entry {
+		value = v
+	}
+
+	if value == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	src := reflect.ValueOf(value)
+	switch {
+	case src.Type().AssignableTo(dst.Type()):
+		dst.Set(src)
+	case src.Type().ConvertibleTo(dst.Type()):
+		dst.Set(src.Convert(dst.Type()))
+	default:
+		return fmt.Errorf("cache: cannot decode %s into %s", src.Type(), dst.Type())
+	}
+	return nil
+}
+
+// register makes t known to gob, ignoring types which are already
+// registered under another name
+func register(t reflect.Type) {
+	defer func() { _ = recover() }()
+	gob.Register(reflect.Zero(t).Interface())
+}
+
+// JSONCodec stores values as JSON. Get returns structs as map[string]any;
+// use GetAs or Scan to decode them into their own type.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// MsgpackCodec stores values as MessagePack, which is more compact than
+// JSON. Like JSONCodec, Get returns structs as maps.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// codecOrDefault returns c, or GobCodec if c is nil
+func codecOrDefault(c Codec) Codec {
+	if c == nil {
+		return GobCodec{}
+	}
+	return c
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+type testUser struct {
+	ID    int
+	Name  string
+	Roles []string
+}
+
+var testCodecs = map[string]Codec{
+	"gob":     GobCodec{},
+	"json":    JSONCodec{},
+	"msgpack": MsgpackCodec{},
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	for name, codec := range testCodecs {
+		redisCache := testRedisCache
+		redisCache.Codec = codec
+
+		badgerCache := testBadgerCache
+		badgerCache.Codec = codec
+
+		for driver, c := range map[string]Cache{"redis": &redisCache, "badger": &badgerCache} {
+			user := testUser{ID: 7, Name: "Jack", Roles: []string{"admin"}}
+
+			if err := c.Set("user", user); err != nil {
+				t.Errorf("%s/%s: %v", driver, name, err)
+				continue
+			}
+
+			got, err := GetAs[testUser](c, "user")
+			if err != nil {
+				t.Errorf("%s/%s: %v", driver, name, err)
+				continue
+			}
+
+			if got.ID != 7 || got.Name != "Jack" || len(got.Roles) != 1 || got.Roles[0] != "admin" {
+				t.Errorf("%s/%s: struct did not round-trip, got %+v", driver, name, got)
+			}
+
+			if err := c.Set("count", 42); err != nil {
+				t.Error(err)
+			}
+
+			if count, err := GetAs[int](c, "count"); err != nil || count != 42 {
+				t.Errorf("%s/%s: expected 42, got %d (%v)", driver, name, count, err)
+			}
+
+			_ = c.Forget("user")
+			_ = c.Forget("count")
+		}
+	}
+}
+
+func TestCodec_NotFound(t *testing.T) {
+	for driver, c := range map[string]Cache{"redis": &testRedisCache, "badger": &testBadgerCache} {
+		if _, err := GetAs[string](c, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", driver, err)
+		}
+	}
+}
+
+func TestCodecByName(t *testing.T) {
+	for _, name := range []string{"", "gob", "JSON", "msgpack"} {
+		if _, err := CodecByName(name); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err := CodecByName("xml"); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+}
@@ -0,0 +1,11 @@
+package cache
+
+// ExpiringCounter is implemented by drivers which can set a counter's expiry
+// in the same step as changing it, so that a counter meant to expire is
+// never left without an expiry
+type ExpiringCounter interface {
+	// IncrementExpiring atomically adds by to the counter under key,
+	// starting from 0, and sets it to expire ttl seconds from now unless it
+	// already expires
+	IncrementExpiring(key string, by int64, ttl int) (int64, error)
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrLockHeld is returned by AcquireLock while another owner holds the lock
+var ErrLockHeld = errors.New("cache: lock is held by another owner")
+
+// ErrLockLost is returned by Renew and Release once the lock has expired or
+// been taken over by another owner
+var ErrLockLost = errors.New("cache: lock is no longer held")
+
+// Locker hands out locks shared by every instance using the same backend
+type Locker interface {
+	// AcquireLock takes the lock called name for ttl, or returns ErrLockHeld
+	// if another owner has it
+	AcquireLock(name string, ttl time.Duration) (*Lock, error)
+}
+
+// Lock is a held lock. Fence increases every time the lock changes hands,
+// so a resource written to by lock holders can reject writes carrying a
+// lower fence than one it has already seen, from an owner whose lock
+// expired while it was paused.
+type Lock struct {
+	Name  string
+	Token string
+	Fence int64
+
+	store lockStore
+}
+
+// lockStore is implemented by each driver to keep locks
+type lockStore interface {
+	renewLock(name, token string, ttl time.Duration) error
+	releaseLock(name, token string) error
+}
+
+// Renew extends the lock to expire ttl from now, as long as it is still
+// held
+func (l *Lock) Renew(ttl time.Duration) error {
+	return l.store.renewLock(l.Name, l.Token, ttl)
+}
+
+// Release gives up the lock, as long as it is still held
+func (l *Lock) Release() error {
+	return l.store.releaseLock(l.Name, l.Token)
+}
+
+const lockPrefix = "lock:"
+
+// newLockToken returns a random token identifying one acquisition of a
+// lock
+func newLockToken() string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// CeilSeconds rounds ttl up to whole seconds, and to at least 1 so that it
+// never means no expiry, for TTLs and headers with second precision
+func CeilSeconds(ttl time.Duration) int {
+	seconds := int((ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCache_Locks(t *testing.T) {
+	for driver, c := range testDrivers(t) {
+		locker := c.(Locker)
+
+		lock, err := locker.AcquireLock("report", 10*time.Second)
+		if err != nil {
+			t.Errorf("%s: %v", driver, err)
+			continue
+		}
+
+		if _, err := locker.AcquireLock("report", 10*time.Second); !errors.Is(err, ErrLockHeld) {
+			t.Errorf("%s: expected ErrLockHeld, got %v", driver, err)
+		}
+
+		if err := lock.Renew(20 * time.Second); err != nil {
+			t.Errorf("%s: %v", driver, err)
+		}
+
+		if err := lock.Release(); err != nil {
+			t.Errorf("%s: %v", driver, err)
+		}
+
+		if err := lock.Release(); !errors.Is(err, ErrLockLost) {
+			t.Errorf("%s: expected ErrLockLost releasing twice, got %v", driver, err)
+		}
+
+		next, err := locker.AcquireLock("report", 10*time.Second)
+		if err != nil {
+			t.Errorf("%s: %v", driver, err)
+			continue
+		}
+
+		if next.Fence <= lock.Fence {
+			t.Errorf("%s: expected the fence to increase, got %d then %d", driver, lock.Fence, next.Fence)
+		}
+
+		if err := lock.Renew(time.Second); !errors.Is(err, ErrLockLost) {
+			t.Errorf("%s: expected a stale owner's renew to fail, got %v", driver, err)
+		}
+
+		_ = next.Release()
+	}
+}
+
+func TestMemoryCache_LockExpires(t *testing.T) {
+	m := NewMemoryCache(0)
+
+	lock, err := m.AcquireLock("job", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := m.AcquireLock("job", time.Second); err != nil {
+		t.Errorf("expected an expired lock to be free, got %v", err)
+	}
+
+	if err := lock.Release(); !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected ErrLockLost, got %v", err)
+	}
+}
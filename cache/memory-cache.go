@@ -0,0 +1,456 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryCache keeps values in process memory. Once it holds Capacity
+// entries the least recently used one is evicted to make room; a Capacity
+// of 0 means no limit. Values are stored encoded with Codec (gob if nil), so
+// changing a value after caching it does not change the cached copy.
+type MemoryCache struct {
+	Capacity int
+	Codec    Codec
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+	tags  map[string]map[string]struct{}
+	locks map[string]*memoryLock
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+	tags    []string
+}
+
+type memoryLock struct {
+	token   string
+	fence   int64
+	expires time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+// NewMemoryCache returns a memory cache holding at most capacity entries
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{Capacity: capacity}
+}
+
+// lazyInit lets the zero MemoryCache be used; callers must hold m.mu
+func (m *MemoryCache) lazyInit() {
+	if m.items == nil {
+		m.items = make(map[string]*list.Element)
+		m.order = list.New()
+		m.tags = make(map[string]map[string]struct{})
+	}
+}
+
+func (m *MemoryCache) Has(str string) (bool, error) {
+	_, err := m.getRaw(str)
+	return err == nil, nil
+}
+
+func (m *MemoryCache) Get(str string) (any, error) {
+	var item any
+	if err := m.Scan(str, &item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Scan decodes the value cached under str into dst, which must be a pointer
+func (m *MemoryCache) Scan(str string, dst any) error {
+	data, err := m.getRaw(str)
+	if err != nil {
+		return err
+	}
+	return codecOrDefault(m.Codec).Unmarshal(data, dst)
+}
+
+func (m *MemoryCache) Set(str string, value any, ttl ...int) error {
+	encoded, err := codecOrDefault(m.Codec).Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return m.setRaw(str, encoded, ttl...)
+}
+
+func (m *MemoryCache) Forget(str string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lazyInit()
+	if el, ok := m.items[str]; ok {
+		m.remove(el)
+	}
+	return nil
+}
+
+func (m *MemoryCache) EmptyByMatch(str string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lazyInit()
+	for key, el := range m.items {
+		if strings.HasPrefix(key, str) {
+			m.remove(el)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryCache) Empty() error {
+	return m.EmptyByMatch("")
+}
+
+// Len returns the number of entries held, including any that have expired
+// but not yet been evicted
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lazyInit()
+	return m.order.Len()
+}
+
+// Remember returns the value cached under str, or calls loader and caches
+// what it returns for ttl seconds if str is not cached
+func (m *MemoryCache) Remember(str string, ttl int, loader func() (any, error), opts ...RememberOptions) (any, error) {
+	return remember(m, codecOrDefault(m.Codec), str, ttl, loader, opts)
+}
+
+func (m *MemoryCache) getRaw(str string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(str)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, str)
+	}
+	return entry.value, nil
+}
+
+func (m *MemoryCache) setRaw(str string, value []byte, ttl ...int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(str, value, ttl...)
+	return nil
+}
+
+// set stores value under str; callers must hold m.mu
+func (m *MemoryCache) set(str string, value []byte, ttl ...int) *memoryEntry {
+	m.lazyInit()
+
+	var expires time.Time
+	if len(ttl) > 0 && ttl[0] > 0 {
+		expires = time.Now().Add(time.Duration(ttl[0]) * time.Second)
+	}
+
+	if el, ok := m.items[str]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value = value
+		entry.expires = expires
+		m.order.MoveToFront(el)
+		return entry
+	}
+
+	entry := &memoryEntry{key: str, value: value, expires: expires}
+	m.items[str] = m.order.PushFront(entry)
+
+	if m.Capacity > 0 {
+		for m.order.Len() > m.Capacity {
+			m.remove(m.order.Back())
+		}
+	}
+	return entry
+}
+
+// lookup returns the live entry for str; callers must hold m.mu
+func (m *MemoryCache) lookup(str string) (*memoryEntry, bool) {
+	m.lazyInit()
+
+	el, ok := m.items[str]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		m.remove(el)
+		return nil, false
+	}
+
+	m.order.MoveToFront(el)
+	return entry, true
+}
+
+// remove drops el from the cache; callers must hold m.mu
+func (m *MemoryCache) remove(el *list.Element) {
+	entry := el.Value.(*memoryEntry)
+
+	m.order.Remove(el)
+	delete(m.items, entry.key)
+
+	for _, tag := range entry.tags {
+		delete(m.tags[tag], entry.key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
+
+// SetIfNotExists stores value under str only if str is not already cached,
+// and reports whether it did
+func (m *MemoryCache) SetIfNotExists(str string, value any, ttl ...int) (bool, error) {
+	encoded, err := codecOrDefault(m.Codec).Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.lookup(str); ok {
+		return false, nil
+	}
+
+	m.set(str, encoded, ttl...)
+	return true, nil
+}
+
+// GetMany fetches several keys at once. Keys which are not cached are left
+// out of the result.
+func (m *MemoryCache) GetMany(strs ...string) (map[string]any, error) {
+	items := make(map[string]any, len(strs))
+
+	for _, str := range strs {
+		var item any
+		if err := m.Scan(str, &item); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		items[str] = item
+	}
+
+	return items, nil
+}
+
+// SetMany stores several values at once
+func (m *MemoryCache) SetMany(items map[string]any, ttl ...int) error {
+	for str, value := range items {
+		if err := m.Set(str, value, ttl...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Increment atomically adds by to the counter under str, starting from 0,
+// and returns the new value. The counter keeps any expiry it already had.
+func (m *MemoryCache) Increment(str string, by int64) (int64, error) {
+	return m.IncrementExpiring(str, by, 0)
+}
+
+// IncrementExpiring atomically adds by to the counter under str, starting
+// from 0, and sets it to expire ttl seconds from now unless it already
+// expires
+func (m *MemoryCache) IncrementExpiring(str string, by int64, ttl int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	entry, ok := m.lookup(str)
+	if ok {
+		var err error
+		if n, err = strconv.ParseInt(string(entry.value), 10, 64); err != nil {
+			return 0, fmt.Errorf("cache: %s is not a counter: %w", str, err)
+		}
+	}
+
+	n += by
+	value := []byte(strconv.FormatInt(n, 10))
+
+	if ok {
+		entry.value = value
+	} else {
+		entry = m.set(str, value)
+	}
+
+	if ttl > 0 && entry.expires.IsZero() {
+		entry.expires = time.Now().Add(time.Duration(ttl) * time.Second)
+	}
+	return n, nil
+}
+
+// Decrement atomically subtracts by from the counter under str
+func (m *MemoryCache) Decrement(str string, by int64) (int64, error) {
+	return m.Increment(str, -by)
+}
+
+// SetWithTags stores value under str and records it against each tag, so
+// that it can be removed along with everything else sharing a tag by
+// FlushTags
+func (m *MemoryCache) SetWithTags(str string, value any, tags []string, ttl ...int) error {
+	encoded, err := codecOrDefault(m.Codec).Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.set(str, encoded, ttl...)
+	for _, tag := range tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]struct{})
+		}
+		if _, ok := m.tags[tag][str]; !ok {
+			m.tags[tag][str] = struct{}{}
+			entry.tags = append(entry.tags, tag)
+		}
+	}
+	return nil
+}
+
+// FlushTags removes every key stored with any of tags
+func (m *MemoryCache) FlushTags(tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lazyInit()
+	for _, tag := range tags {
+		for str := range m.tags[tag] {
+			if el, ok := m.items[str]; ok {
+				m.remove(el)
+			}
+		}
+		delete(m.tags, tag)
+	}
+	return nil
+}
+
+// TTL returns how long str has left before it expires, or 0 if it never
+// expires
+func (m *MemoryCache) TTL(str string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(str)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, str)
+	}
+
+	if entry.expires.IsZero() {
+		return 0, nil
+	}
+	return time.Until(entry.expires), nil
+}
+
+// Touch sets str to expire ttl seconds from now, or never if ttl is 0
+func (m *MemoryCache) Touch(str string, ttl int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(str)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, str)
+	}
+
+	entry.expires = time.Time{}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(time.Duration(ttl) * time.Second)
+	}
+	return nil
+}
+
+// AcquireLock takes the lock called name for ttl, or returns ErrLockHeld if
+// another owner has it. Memory locks are only shared within this process,
+// and are kept apart from cached values so that eviction never drops them.
+func (m *MemoryCache) AcquireLock(name string, ttl time.Duration) (*Lock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.locks == nil {
+		m.locks = make(map[string]*memoryLock)
+	}
+
+	held, ok := m.locks[name]
+	if ok && time.Now().Before(held.expires) {
+		return nil, ErrLockHeld
+	}
+
+	var fence int64 = 1
+	if ok {
+		fence = held.fence + 1
+	}
+
+	token := newLockToken()
+	m.locks[name] = &memoryLock{token: token, fence: fence, expires: time.Now().Add(ttl)}
+
+	return &Lock{Name: name, Token: token, Fence: fence, store: m}, nil
+}
+
+func (m *MemoryCache) renewLock(name, token string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	held, ok := m.locks[name]
+	if !ok || held.token != token || time.Now().After(held.expires) {
+		return ErrLockLost
+	}
+
+	held.expires = time.Now().Add(ttl)
+	return nil
+}
+
+func (m *MemoryCache) releaseLock(name, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	held, ok := m.locks[name]
+	if !ok || held.token != token || time.Now().After(held.expires) {
+		return ErrLockLost
+	}
+
+	// keep the fence, so the next owner's is higher
+	held.token = ""
+	held.expires = time.Time{}
+	return nil
+}
+
+// TakeToken takes a token from the bucket under key, reporting whether
+// there was one to take
+func (m *MemoryCache) TakeToken(key string, b Bucket) (BucketState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key = bucketPrefix + key
+	now := time.Now().UnixMilli()
+
+	tat := now
+	if entry, ok := m.lookup(key); ok {
+		tat, _ = strconv.ParseInt(string(entry.value), 10, 64)
+	}
+
+	allowed, tat := b.take(tat, now)
+	if allowed {
+		m.set(key, []byte(strconv.FormatInt(tat, 10))).expires = time.UnixMilli(tat)
+	}
+
+	return b.state(allowed, tat, now), nil
+}
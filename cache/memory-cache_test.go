@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_GetSet(t *testing.T) {
+	c := NewMemoryCache(0)
+
+	if err := c.Set("test", "hello world"); err != nil {
+		t.Error(err)
+	}
+
+	x, err := c.Get("test")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if x != "hello world" {
+		t.Error("did not receive correct value from cache")
+	}
+
+	if err := c.Forget("test"); err != nil {
+		t.Error(err)
+	}
+
+	if inCache, _ := c.Has("test"); inCache {
+		t.Error("\"test\" found in cache but it should not be there")
+	}
+}
+
+func TestMemoryCache_LRU(t *testing.T) {
+	c := NewMemoryCache(2)
+
+	_ = c.Set("one", 1)
+	_ = c.Set("two", 2)
+
+	// touch "one" so that "two" is the least recently used
+	if _, err := c.Get("one"); err != nil {
+		t.Error(err)
+	}
+
+	_ = c.Set("three", 3)
+
+	if inCache, _ := c.Has("two"); inCache {
+		t.Error("\"two\" found in cache but it should have been evicted")
+	}
+
+	for _, key := range []string{"one", "three"} {
+		if inCache, _ := c.Has(key); !inCache {
+			t.Errorf("%q not found in cache but it should be there", key)
+		}
+	}
+
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestMemoryCache_TTL(t *testing.T) {
+	var c MemoryCache
+
+	_ = c.Set("short", "lived", 1)
+
+	if inCache, _ := c.Has("short"); !inCache {
+		t.Error("\"short\" not found in cache but it should be there")
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if inCache, _ := c.Has("short"); inCache {
+		t.Error("\"short\" found in cache but it should have expired")
+	}
+}
+
+func TestMemoryCache_EmptyByMatch(t *testing.T) {
+	c := NewMemoryCache(0)
+
+	_ = c.Set("one", "two")
+	_ = c.Set("three", "four")
+
+	if err := c.EmptyByMatch("o"); err != nil {
+		t.Error(err)
+	}
+
+	if inCache, _ := c.Has("one"); inCache {
+		t.Error("\"one\" found in cache but it should not be there")
+	}
+
+	if inCache, _ := c.Has("three"); !inCache {
+		t.Error("\"three\" not found in cache but it should be there")
+	}
+}
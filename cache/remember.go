@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// RememberOptions tune how Remember refreshes a value
+type RememberOptions struct {
+	// Stale is how many seconds after ttl has passed the old value may still
+	// be returned while a fresh one is loaded in the background
+	Stale int
+	// Beta enables early probabilistic refresh: values are occasionally
+	// reloaded in the background shortly before they expire, more often the
+	// closer they are to expiry and the longer the loader takes. 1 is a good
+	// default; 0 disables it.
+	Beta float64
+}
+
+// rawStore is implemented by every driver so that Remember can work on
+// encoded values
+type rawStore interface {
+	getRaw(string) ([]byte, error)
+	setRaw(string, []byte, ...int) error
+}
+
+// rememberMeta is kept beside a remembered value, under the key with
+// rememberSuffix appended
+type rememberMeta struct {
+	Expires int64 `json:"e"`
+	Delta   int64 `json:"d"`
+}
+
+const rememberSuffix = ":remember"
+
+// loads makes sure only one loader runs at a time for each key, however
+// many requests miss the cache at once
+var loads singleflight.Group
+
+// remember implements Cache.Remember for a driver: it returns the value
+// cached under key, or calls loader once however many callers are waiting,
+// caches the result for ttl seconds and returns it
+func remember(store rawStore, codec Codec, key string, ttl int, loader func() (any, error), opts []RememberOptions) (any, error) {
+	var opt RememberOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	load := func() (any, error) {
+		value, err, _ := loads.Do(fmt.Sprintf("%p|%s", store, key), func() (any, error) {
+			return rememberLoad(store, codec, key, ttl, loader, opt)
+		})
+		return value, err
+	}
+
+	data, err := store.getRaw(key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return load()
+		}
+		return nil, err
+	}
+
+	var value any
+	if err := codec.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	if ttl > 0 && rememberExpiring(store, key, opt) {
+		// refresh in the background, keeping the current value meanwhile
+		loads.DoChan(fmt.Sprintf("%p|%s", store, key), func() (any, error) {
+			return rememberLoad(store, codec, key, ttl, loader, opt)
+		})
+	}
+
+	return value, nil
+}
+
+// rememberLoad calls loader and caches what it returns. With a stale window
+// the value is kept for ttl+Stale seconds, and the metadata records when it
+// should be refreshed.
+func rememberLoad(store rawStore, codec Codec, key string, ttl int, loader func() (any, error), opt RememberOptions) (any, error) {
+	start := time.Now()
+
+	value, err := loader()
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		return value, store.setRaw(key, encoded)
+	}
+
+	keep := ttl + opt.Stale
+	if err := store.setRaw(key, encoded, keep); err != nil {
+		return nil, err
+	}
+
+	meta, err := json.Marshal(rememberMeta{
+		Expires: start.Add(time.Duration(ttl) * time.Second).UnixNano(),
+		Delta:   int64(time.Since(start)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value, store.setRaw(key+rememberSuffix, meta, keep)
+}
+
+// rememberExpiring reports whether a cached value is stale, or due an early
+// refresh
+func rememberExpiring(store rawStore, key string, opt RememberOptions) bool {
+	if opt.Stale <= 0 && opt.Beta <= 0 {
+		return false
+	}
+
+	data, err := store.getRaw(key + rememberSuffix)
+	if err != nil {
+		return false
+	}
+
+	var meta rememberMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return false
+	}
+
+	now := time.Now().UnixNano()
+	if now >= meta.Expires {
+		return true
+	}
+
+	// XFetch: refresh early with a probability that grows as expiry nears
+	if opt.Beta > 0 {
+		gap := float64(meta.Delta) * opt.Beta * -math.Log(1-rand.Float64())
+		return float64(now)+gap >= float64(meta.Expires)
+	}
+	return false
+}
+
+// Remember returns the value cached under key decoded as a T, or calls fn
+// and caches what it returns for ttl seconds (forever if ttl is 0) if key is
+// not cached. Concurrent misses for the same key share a single call to fn.
+func Remember[T any](c Cache, key string, ttl int, fn func() (T, error), opts ...RememberOptions) (T, error) {
+	var value T
+
+	item, err := c.Remember(key, ttl, func() (any, error) {
+		return fn()
+	}, opts...)
+	if err != nil {
+		return value, err
+	}
+
+	if v, ok := item.(T); ok {
+		return v, nil
+	}
+
+	// codecs such as JSON return structs as maps, so decode again as a T
+	err = c.Scan(key, &value)
+	return value, err
+}
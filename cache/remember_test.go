@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemember(t *testing.T) {
+	_ = testRedisCache.Forget("remembered")
+
+	calls := 0
+	load := func() (testUser, error) {
+		calls++
+		return testUser{ID: calls, Name: "loaded"}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		user, err := Remember(&testRedisCache, "remembered", 60, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if user.ID != 1 || user.Name != "loaded" {
+			t.Errorf("unexpected value %+v", user)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("expected the loader to be called once, got %d", calls)
+	}
+}
+
+func TestRemember_Singleflight(t *testing.T) {
+	for driver, c := range map[string]Cache{"redis": &testRedisCache, "badger": &testBadgerCache} {
+		_ = c.Forget("stampede")
+
+		var calls atomic.Int32
+		loader := func() (any, error) {
+			calls.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			return "expensive", nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				value, err := c.Remember("stampede", 60, loader)
+				if err != nil {
+					t.Error(err)
+				}
+
+				if value != "expensive" {
+					t.Errorf("%s: expected \"expensive\", got %v", driver, value)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if n := calls.Load(); n != 1 {
+			t.Errorf("%s: expected the loader to be called once, got %d", driver, n)
+		}
+	}
+}
+
+func TestRemember_StaleWhileRevalidate(t *testing.T) {
+	c := NewMemoryCache(0)
+
+	var calls atomic.Int32
+	loader := func() (any, error) {
+		return int(calls.Add(1)), nil
+	}
+
+	opts := RememberOptions{Stale: 10}
+
+	if value, _ := c.Remember("counter", 1, loader, opts); value != 1 {
+		t.Fatalf("expected 1, got %v", value)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	// past ttl, the old value is served while it is refreshed
+	if value, _ := c.Remember("counter", 1, loader, opts); value != 1 {
+		t.Errorf("expected the stale value 1, got %v", value)
+	}
+
+	waitFor(t, func() bool {
+		value, _ := c.Get("counter")
+		return value == 2
+	})
+}
+
+func TestRemember_EarlyRefresh(t *testing.T) {
+	c := NewMemoryCache(0)
+
+	var calls atomic.Int32
+	loader := func() (any, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return "value", nil
+	}
+
+	// a huge beta makes a refresh before expiry all but certain
+	opts := RememberOptions{Beta: 1e6}
+
+	if _, err := c.Remember("early", 60, loader, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Remember("early", 60, loader, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool { return calls.Load() == 2 })
+}
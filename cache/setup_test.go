@@ -35,20 +35,21 @@ func TestMain(m *testing.M) {
 
 	defer testRedisCache.Conn.Close()
 
-	_ = os.RemoveAll("./testdata/tmp/badger")
-
-	if _, err := os.Stat("./testdata/tmp"); os.IsNotExist(err) {
-		if err := os.Mkdir("./testdata/tmp", 0755); err != nil {
-			log.Fatal(err)
-		}
+	dir, err := os.MkdirTemp("", "celeritas-badger")
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if err := os.Mkdir("./testdata/tmp/badger", 0755); err != nil {
+	db, err := badger.Open(badger.DefaultOptions(dir))
+	if err != nil {
 		log.Fatal(err)
 	}
-
-	db, _ := badger.Open(badger.DefaultOptions("./testdata/tmp/badger"))
 	testBadgerCache.Conn = db
+	testBadgerCache.Prefix = "test-celeritas"
+
+	code := m.Run()
 
-	os.Exit(m.Run())
+	_ = db.Close()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
 }
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func testDrivers(t *testing.T) map[string]Cache {
+	return map[string]Cache{
+		"redis":  &testRedisCache,
+		"badger": &testBadgerCache,
+		"memory": NewMemoryCache(0),
+		"tiered": newTestTieredCache(t),
+	}
+}
+
+func TestCache_Tags(t *testing.T) {
+	for driver, c := range testDrivers(t) {
+		_ = c.SetWithTags("user:1", "jack", []string{"users"})
+		_ = c.SetWithTags("user:2", "jill", []string{"users", "admins"})
+		_ = c.SetWithTags("post:1", "hello", []string{"posts"})
+
+		if err := c.FlushTags("users"); err != nil {
+			t.Errorf("%s: %v", driver, err)
+		}
+
+		for _, key := range []string{"user:1", "user:2"} {
+			if inCache, _ := c.Has(key); inCache {
+				t.Errorf("%s: %q found in cache but it should have been flushed", driver, key)
+			}
+		}
+
+		if inCache, _ := c.Has("post:1"); !inCache {
+			t.Errorf("%s: \"post:1\" not found in cache but it should be there", driver)
+		}
+
+		_ = c.Forget("post:1")
+	}
+}
+
+func TestCache_Counters(t *testing.T) {
+	for driver, c := range testDrivers(t) {
+		_ = c.Forget("hits")
+
+		if n, err := c.Increment("hits", 5); err != nil || n != 5 {
+			t.Errorf("%s: expected 5, got %d (%v)", driver, n, err)
+		}
+
+		if inCache, err := c.Has("hits"); err != nil || !inCache {
+			t.Errorf("%s: expected the counter to be found, got %v (%v)", driver, inCache, err)
+		}
+
+		if n, err := c.Decrement("hits", 2); err != nil || n != 3 {
+			t.Errorf("%s: expected 3, got %d (%v)", driver, n, err)
+		}
+
+		if n, _ := c.Increment("hits", 0); n != 3 {
+			t.Errorf("%s: expected the counter to read 3, got %d", driver, n)
+		}
+
+		_ = c.Set("not-a-counter", "hello")
+		if _, err := c.Increment("not-a-counter", 1); err == nil {
+			t.Errorf("%s: expected an error incrementing a non-numeric value", driver)
+		}
+
+		_ = c.Forget("hits")
+		_ = c.Forget("not-a-counter")
+	}
+}
+
+func TestCache_IncrementExpiring(t *testing.T) {
+	for driver, c := range testDrivers(t) {
+		counter, ok := c.(ExpiringCounter)
+		if !ok {
+			t.Errorf("%s: expected an ExpiringCounter", driver)
+			continue
+		}
+		_ = c.Forget("window")
+
+		if n, err := counter.IncrementExpiring("window", 1, 60); err != nil || n != 1 {
+			t.Errorf("%s: expected 1, got %d (%v)", driver, n, err)
+		}
+
+		// an expiry already set is kept
+		_, _ = counter.IncrementExpiring("window", 1, 600)
+		if ttl, err := c.TTL("window"); err != nil || ttl <= 0 || ttl > time.Minute {
+			t.Errorf("%s: expected the counter to expire within a minute, got %v (%v)", driver, ttl, err)
+		}
+
+		// a counter without one is given one
+		_ = c.Touch("window", 0)
+		if n, err := counter.IncrementExpiring("window", 1, 60); err != nil || n != 3 {
+			t.Errorf("%s: expected 3, got %d (%v)", driver, n, err)
+		}
+		if ttl, err := c.TTL("window"); err != nil || ttl <= 0 {
+			t.Errorf("%s: expected the counter to expire again, got %v (%v)", driver, ttl, err)
+		}
+
+		_ = c.Forget("window")
+	}
+}
+
+func TestCache_SetIfNotExists(t *testing.T) {
+	for driver, c := range testDrivers(t) {
+		_ = c.Forget("once")
+
+		set, err := c.SetIfNotExists("once", "first", 60)
+		if err != nil || !set {
+			t.Errorf("%s: expected the first value to be set (%v)", driver, err)
+		}
+
+		set, err = c.SetIfNotExists("once", "second", 60)
+		if err != nil || set {
+			t.Errorf("%s: expected the second value not to be set (%v)", driver, err)
+		}
+
+		if x, _ := c.Get("once"); x != "first" {
+			t.Errorf("%s: expected \"first\", got %v", driver, x)
+		}
+
+		_ = c.Forget("once")
+	}
+}
+
+func TestCache_GetSetMany(t *testing.T) {
+	for driver, c := range testDrivers(t) {
+		_ = c.Forget("missing")
+
+		if err := c.SetMany(map[string]any{"a": "one", "b": "two"}, 60); err != nil {
+			t.Errorf("%s: %v", driver, err)
+		}
+
+		items, err := c.GetMany("a", "b", "missing")
+		if err != nil {
+			t.Errorf("%s: %v", driver, err)
+		}
+
+		if len(items) != 2 || items["a"] != "one" || items["b"] != "two" {
+			t.Errorf("%s: unexpected items %v", driver, items)
+		}
+
+		_ = c.Forget("a")
+		_ = c.Forget("b")
+	}
+}
+
+func TestRedisCache_TagsExpire(t *testing.T) {
+	c := &testRedisCache
+	tagKey := c.tagKey("expiring")
+
+	_ = c.SetWithTags("short", "a", []string{"expiring"}, 10)
+	_ = c.SetWithTags("long", "b", []string{"expiring"}, 100)
+	_ = c.SetWithTags("shorter", "c", []string{"expiring"}, 5)
+
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	ttl, err := redis.Int(conn.Do("TTL", tagKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl <= 10 || ttl > 100 {
+		t.Errorf("expected the tag to live as long as its longest lived member, got a TTL of %d", ttl)
+	}
+
+	_ = c.SetWithTags("forever", "d", []string{"expiring"})
+	if ttl, _ := redis.Int(conn.Do("TTL", tagKey)); ttl != -1 {
+		t.Errorf("expected a member without a TTL to keep the tag, got a TTL of %d", ttl)
+	}
+
+	_ = c.FlushTags("expiring")
+}
@@ -0,0 +1,452 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// TieredCache serves reads from a MemoryCache (L1) in front of a shared
+// RedisCache (L2). Writes go to both, and other nodes are told over Redis
+// pub/sub to drop their local copy of anything that changed. Values are
+// encoded once with the L2 codec, so L1.Codec is not used.
+type TieredCache struct {
+	L1 *MemoryCache
+	L2 *RedisCache
+	// L1TTL is how many seconds a value may be served from memory before it
+	// is read from Redis again, DefaultL1TTL if 0
+	L1TTL int
+
+	node    string
+	mu      sync.Mutex
+	psc     *redis.PubSubConn
+	closing chan struct{}
+	done    chan struct{}
+}
+
+// DefaultL1TTL bounds how stale a node's memory cache can get if an
+// invalidation message is missed
+const DefaultL1TTL = 60
+
+const tieredReconnectDelay = time.Second
+
+// NewTieredCache returns a tiered cache using l1 in front of l2. Call
+// Subscribe to receive invalidations from other nodes.
+func NewTieredCache(l1 *MemoryCache, l2 *RedisCache) *TieredCache {
+	node := make([]byte, 8)
+	_, _ = rand.Read(node)
+
+	return &TieredCache{L1: l1, L2: l2, node: hex.EncodeToString(node)}
+}
+
+func (t *TieredCache) channel() string {
+	return t.L2.Prefix + ":cache-invalidate"
+}
+
+func (t *TieredCache) l1TTL(ttl ...int) int {
+	l1TTL := t.L1TTL
+	if l1TTL <= 0 {
+		l1TTL = DefaultL1TTL
+	}
+	if len(ttl) > 0 && ttl[0] > 0 && ttl[0] < l1TTL {
+		return ttl[0]
+	}
+	return l1TTL
+}
+
+func (t *TieredCache) Has(str string) (bool, error) {
+	if ok, _ := t.L1.Has(str); ok {
+		return true, nil
+	}
+	return t.L2.Has(str)
+}
+
+func (t *TieredCache) Get(str string) (any, error) {
+	var item any
+	if err := t.Scan(str, &item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Scan decodes the value cached under str into dst, which must be a pointer
+func (t *TieredCache) Scan(str string, dst any) error {
+	data, err := t.getRaw(str)
+	if err != nil {
+		return err
+	}
+
+	return codecOrDefault(t.L2.Codec).Unmarshal(data, dst)
+}
+
+func (t *TieredCache) Set(str string, value any, ttl ...int) error {
+	encoded, err := codecOrDefault(t.L2.Codec).Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return t.setRaw(str, encoded, ttl...)
+}
+
+// Remember returns the value cached under str, or calls loader and caches
+// what it returns for ttl seconds if str is not cached
+func (t *TieredCache) Remember(str string, ttl int, loader func() (any, error), opts ...RememberOptions) (any, error) {
+	return remember(t, codecOrDefault(t.L2.Codec), str, ttl, loader, opts)
+}
+
+func (t *TieredCache) getRaw(str string) ([]byte, error) {
+	data, err := t.L1.getRaw(str)
+	if err == nil {
+		return data, nil
+	}
+
+	data, err = t.L2.getRaw(str)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = t.L1.setRaw(str, data, t.l1TTL())
+	return data, nil
+}
+
+func (t *TieredCache) setRaw(str string, encoded []byte, ttl ...int) error {
+	if err := t.L2.setRaw(str, encoded, ttl...); err != nil {
+		return err
+	}
+
+	_ = t.L1.setRaw(str, encoded, t.l1TTL(ttl...))
+	return t.publish("forget", str)
+}
+
+func (t *TieredCache) Forget(str string) error {
+	_ = t.L1.Forget(str)
+	if err := t.L2.Forget(str); err != nil {
+		return err
+	}
+	return t.publish("forget", str)
+}
+
+func (t *TieredCache) EmptyByMatch(str string) error {
+	_ = t.L1.EmptyByMatch(str)
+	if err := t.L2.EmptyByMatch(str); err != nil {
+		return err
+	}
+	return t.publish("match", str)
+}
+
+func (t *TieredCache) Empty() error {
+	_ = t.L1.Empty()
+	if err := t.L2.Empty(); err != nil {
+		return err
+	}
+	return t.publish("empty", "")
+}
+
+// publish tells the other nodes to invalidate key. Messages have the form
+// node|op|key.
+func (t *TieredCache) publish(op, key string) error {
+	conn := t.L2.Conn.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("PUBLISH", t.channel(), t.node+"|"+op+"|"+key)
+	return err
+}
+
+// invalidate applies a message published by another node
+func (t *TieredCache) invalidate(message string) {
+	parts := strings.SplitN(message, "|", 3)
+	if len(parts) != 3 || parts[0] == t.node {
+		return
+	}
+
+	switch parts[1] {
+	case "forget":
+		_ = t.L1.Forget(parts[2])
+	case "match":
+		_ = t.L1.EmptyByMatch(parts[2])
+	case "empty":
+		_ = t.L1.Empty()
+	}
+}
+
+// Subscribe starts listening for invalidations from other nodes, and
+// returns once the subscription is active. If the connection to Redis drops
+// it is re-established and the memory cache emptied, since messages may
+// have been missed in between.
+func (t *TieredCache) Subscribe() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closing != nil {
+		return errors.New("cache: already subscribed")
+	}
+
+	if err := t.subscribe(); err != nil {
+		return err
+	}
+
+	t.closing = make(chan struct{})
+	t.done = make(chan struct{})
+	go t.listen()
+	return nil
+}
+
+// subscribe opens a new pub/sub connection; callers must hold t.mu
+func (t *TieredCache) subscribe() error {
+	// a dedicated connection rather than one from the pool, since closing a
+	// pooled connection waits for replies that the listener is reading
+	var conn redis.Conn
+	var err error
+	if t.L2.Conn.DialContext != nil {
+		conn, err = t.L2.Conn.DialContext(context.Background())
+	} else {
+		conn, err = t.L2.Conn.Dial()
+	}
+	if err != nil {
+		return err
+	}
+
+	psc := &redis.PubSubConn{Conn: conn}
+	if err := psc.Subscribe(t.channel()); err != nil {
+		psc.Close()
+		return err
+	}
+
+	// wait for the confirmation so no message published after Subscribe
+	// returns is missed
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Subscription:
+			t.psc = psc
+			return nil
+		case error:
+			psc.Close()
+			return v
+		}
+	}
+}
+
+func (t *TieredCache) listen() {
+	defer close(t.done)
+
+	for {
+		t.mu.Lock()
+		psc := t.psc
+		t.mu.Unlock()
+
+		if psc != nil {
+			t.receive(psc)
+		}
+
+		select {
+		case <-t.closing:
+			return
+		case <-time.After(tieredReconnectDelay):
+		}
+
+		t.mu.Lock()
+		select {
+		case <-t.closing:
+			// Close ran while we waited for the lock
+			t.mu.Unlock()
+			return
+		default:
+		}
+
+		if err := t.subscribe(); err != nil {
+			t.psc = nil
+		} else {
+			_ = t.L1.Empty()
+		}
+		t.mu.Unlock()
+	}
+}
+
+// receive handles messages until the connection fails or is closed
+func (t *TieredCache) receive(psc *redis.PubSubConn) {
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			t.invalidate(string(v.Data))
+		case error:
+			psc.Close()
+			return
+		}
+	}
+}
+
+// Close stops listening for invalidations
+func (t *TieredCache) Close() error {
+	t.mu.Lock()
+	if t.closing == nil {
+		t.mu.Unlock()
+		return nil
+	}
+
+	close(t.closing)
+	psc := t.psc
+	t.mu.Unlock()
+
+	if psc != nil {
+		_ = psc.Close()
+	}
+
+	<-t.done
+
+	t.mu.Lock()
+	t.closing = nil
+	t.mu.Unlock()
+	return nil
+}
+
+// forgetLocal drops keys from this node's memory and tells the other nodes
+// to do the same
+func (t *TieredCache) forgetLocal(strs ...string) error {
+	for _, str := range strs {
+		_ = t.L1.Forget(str)
+		if err := t.publish("forget", str); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetIfNotExists stores value under str only if str is not already cached,
+// and reports whether it did
+func (t *TieredCache) SetIfNotExists(str string, value any, ttl ...int) (bool, error) {
+	set, err := t.L2.SetIfNotExists(str, value, ttl...)
+	if err != nil || !set {
+		return set, err
+	}
+	return true, t.forgetLocal(str)
+}
+
+// GetMany fetches several keys, reading from Redis only those which are not
+// in memory. Keys which are not cached are left out of the result.
+func (t *TieredCache) GetMany(strs ...string) (map[string]any, error) {
+	items, err := t.L1.GetMany(strs...)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, str := range strs {
+		if _, ok := items[str]; !ok {
+			missing = append(missing, str)
+		}
+	}
+
+	if len(missing) == 0 {
+		return items, nil
+	}
+
+	for _, str := range missing {
+		data, err := t.L2.getRaw(str)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var item any
+		if err := codecOrDefault(t.L2.Codec).Unmarshal(data, &item); err != nil {
+			return nil, err
+		}
+
+		_ = t.L1.setRaw(str, data, t.l1TTL())
+		items[str] = item
+	}
+
+	return items, nil
+}
+
+// SetMany stores several values at once
+func (t *TieredCache) SetMany(items map[string]any, ttl ...int) error {
+	if err := t.L2.SetMany(items, ttl...); err != nil {
+		return err
+	}
+
+	strs := make([]string, 0, len(items))
+	for str := range items {
+		strs = append(strs, str)
+	}
+	return t.forgetLocal(strs...)
+}
+
+// Increment atomically adds by to the counter under str. Counters are
+// always read from Redis.
+func (t *TieredCache) Increment(str string, by int64) (int64, error) {
+	n, err := t.L2.Increment(str, by)
+	if err != nil {
+		return n, err
+	}
+	return n, t.forgetLocal(str)
+}
+
+// IncrementExpiring atomically adds by to the counter under str, and sets
+// it to expire ttl seconds from now unless it already expires. Counters are
+// always read from Redis.
+func (t *TieredCache) IncrementExpiring(str string, by int64, ttl int) (int64, error) {
+	n, err := t.L2.IncrementExpiring(str, by, ttl)
+	if err != nil {
+		return n, err
+	}
+	return n, t.forgetLocal(str)
+}
+
+// Decrement atomically subtracts by from the counter under str
+func (t *TieredCache) Decrement(str string, by int64) (int64, error) {
+	return t.Increment(str, -by)
+}
+
+// SetWithTags stores value under str and records it against each tag, so
+// that it can be removed along with everything else sharing a tag by
+// FlushTags
+func (t *TieredCache) SetWithTags(str string, value any, tags []string, ttl ...int) error {
+	if err := t.L2.SetWithTags(str, value, tags, ttl...); err != nil {
+		return err
+	}
+	return t.forgetLocal(str)
+}
+
+// FlushTags removes every key stored with any of tags, on every node
+func (t *TieredCache) FlushTags(tags ...string) error {
+	flushed, err := t.L2.flushTags(tags...)
+	if err != nil {
+		return err
+	}
+	return t.forgetLocal(flushed...)
+}
+
+// TTL returns how long str has left in Redis before it expires, or 0 if it
+// never expires
+func (t *TieredCache) TTL(str string) (time.Duration, error) {
+	return t.L2.TTL(str)
+}
+
+// Touch sets str to expire ttl seconds from now, or never if ttl is 0
+func (t *TieredCache) Touch(str string, ttl int) error {
+	if err := t.L2.Touch(str, ttl); err != nil {
+		return err
+	}
+	return t.forgetLocal(str)
+}
+
+// AcquireLock takes the lock called name for ttl, or returns ErrLockHeld if
+// another owner has it. Locks are kept in Redis only.
+func (t *TieredCache) AcquireLock(name string, ttl time.Duration) (*Lock, error) {
+	return t.L2.AcquireLock(name, ttl)
+}
+
+// TakeToken takes a token from the bucket under key, which is kept in
+// Redis only
+func (t *TieredCache) TakeToken(key string, b Bucket) (BucketState, error) {
+	return t.L2.TakeToken(key, b)
+}
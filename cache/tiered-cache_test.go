@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestTieredCache(t *testing.T) *TieredCache {
+	l2 := testRedisCache
+	l2.Prefix = "test-tiered"
+
+	c := NewTieredCache(NewMemoryCache(100), &l2)
+	if err := c.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTieredCache_ReadsThrough(t *testing.T) {
+	node := newTestTieredCache(t)
+
+	if err := node.L2.Set("shared", "from redis"); err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := node.Get("shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if x != "from redis" {
+		t.Errorf("expected \"from redis\", got %v", x)
+	}
+
+	if inCache, _ := node.L1.Has("shared"); !inCache {
+		t.Error("expected the value to be kept in memory after reading it")
+	}
+}
+
+func TestTieredCache_Invalidation(t *testing.T) {
+	one := newTestTieredCache(t)
+	two := newTestTieredCache(t)
+
+	if err := one.Set("user", "first"); err != nil {
+		t.Fatal(err)
+	}
+
+	if x, _ := two.Get("user"); x != "first" {
+		t.Fatalf("expected \"first\", got %v", x)
+	}
+
+	if err := one.Set("user", "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		inCache, _ := two.L1.Has("user")
+		return !inCache
+	})
+
+	if x, _ := two.Get("user"); x != "second" {
+		t.Errorf("expected \"second\", got %v", x)
+	}
+
+	if inCache, _ := one.L1.Has("user"); !inCache {
+		t.Error("the writing node should keep its own copy")
+	}
+
+	if err := two.Forget("user"); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		inCache, _ := one.Has("user")
+		return !inCache
+	})
+}
@@ -0,0 +1,90 @@
+package cache
+
+import "time"
+
+// TokenBucket is implemented by drivers which can rate limit with a token
+// bucket shared by every instance using the same backend
+type TokenBucket interface {
+	// TakeToken takes a token from the bucket under key, reporting whether
+	// there was one to take
+	TakeToken(key string, b Bucket) (BucketState, error)
+}
+
+// Bucket describes a token bucket which holds up to Burst tokens and is
+// refilled with Rate tokens every Period
+type Bucket struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// BucketState is the state of a bucket after taking a token from it
+type BucketState struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until a token is available, when none was
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+const bucketPrefix = "bucket:"
+
+// Buckets are kept as the time at which they will be full again, in unix
+// milliseconds, following the generic cell rate algorithm: each token taken
+// moves that time on by one interval, and a token may be taken as long as
+// it stays within Burst intervals of now.
+
+// interval returns the milliseconds taken to add one token
+func (b Bucket) interval() int64 {
+	rate := b.Rate
+	if rate < 1 {
+		rate = 1
+	}
+
+	interval := b.Period.Milliseconds() / int64(rate)
+	if interval < 1 {
+		return 1
+	}
+	return interval
+}
+
+func (b Bucket) burst() int64 {
+	if b.Burst < 1 {
+		if b.Rate < 1 {
+			return 1
+		}
+		return int64(b.Rate)
+	}
+	return int64(b.Burst)
+}
+
+// take takes a token from a bucket full at tat, returning whether it could
+// and when the bucket will be full afterwards
+func (b Bucket) take(tat, now int64) (bool, int64) {
+	if tat < now {
+		tat = now
+	}
+
+	next := tat + b.interval()
+	if now < next-b.burst()*b.interval() {
+		return false, tat
+	}
+	return true, next
+}
+
+// state describes a bucket full at tat
+func (b Bucket) state(allowed bool, tat, now int64) BucketState {
+	interval := b.interval()
+	state := BucketState{
+		Allowed: allowed,
+		Reset:   time.Duration(tat-now) * time.Millisecond,
+	}
+
+	if allowed {
+		state.Remaining = int((now - (tat - b.burst()*interval)) / interval)
+	} else {
+		state.RetryAfter = time.Duration(tat+interval-b.burst()*interval-now) * time.Millisecond
+	}
+	return state
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache_TakeToken(t *testing.T) {
+	bucket := Bucket{Rate: 3, Period: time.Minute, Burst: 3}
+
+	for driver, c := range testDrivers(t) {
+		limiter := c.(TokenBucket)
+		key := "login:" + driver
+		_ = c.Forget(bucketPrefix + key)
+
+		for i := 2; i >= 0; i-- {
+			state, err := limiter.TakeToken(key, bucket)
+			if err != nil || !state.Allowed {
+				t.Errorf("%s: expected a token to be available (%v)", driver, err)
+			}
+			if state.Remaining != i {
+				t.Errorf("%s: expected %d tokens remaining, got %d", driver, i, state.Remaining)
+			}
+		}
+
+		state, err := limiter.TakeToken(key, bucket)
+		if err != nil || state.Allowed {
+			t.Errorf("%s: expected the bucket to be empty (%v)", driver, err)
+		}
+
+		if state.RetryAfter <= 19*time.Second || state.RetryAfter > 20*time.Second {
+			t.Errorf("%s: expected to retry after about 20s, got %s", driver, state.RetryAfter)
+		}
+
+		if state.Reset <= 59*time.Second || state.Reset > time.Minute {
+			t.Errorf("%s: expected the bucket to refill in about a minute, got %s", driver, state.Reset)
+		}
+
+		_ = c.Forget(bucketPrefix + key)
+	}
+}
+
+func TestMemoryCache_TakeTokenRefills(t *testing.T) {
+	m := NewMemoryCache(0)
+	bucket := Bucket{Rate: 1, Period: 20 * time.Millisecond, Burst: 1}
+
+	if state, _ := m.TakeToken("api", bucket); !state.Allowed {
+		t.Error("expected a token to be available")
+	}
+
+	if state, _ := m.TakeToken("api", bucket); state.Allowed {
+		t.Error("expected the bucket to be empty")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if state, _ := m.TakeToken("api", bucket); !state.Allowed {
+		t.Error("expected the bucket to have refilled")
+	}
+}
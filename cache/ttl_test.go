@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCache_TTLTouch(t *testing.T) {
+	for driver, c := range testDrivers(t) {
+		_ = c.Forget("missing")
+
+		if _, err := c.TTL("missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", driver, err)
+		}
+
+		if err := c.Touch("missing", 10); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound touching a missing key, got %v", driver, err)
+		}
+
+		_ = c.Set("session", "data", 60)
+
+		ttl, err := c.TTL("session")
+		if err != nil || ttl <= 50*time.Second || ttl > 60*time.Second {
+			t.Errorf("%s: expected a ttl of about 60s, got %v (%v)", driver, ttl, err)
+		}
+
+		if err := c.Touch("session", 600); err != nil {
+			t.Errorf("%s: %v", driver, err)
+		}
+
+		if ttl, _ := c.TTL("session"); ttl <= 590*time.Second {
+			t.Errorf("%s: expected touch to extend the ttl, got %v", driver, ttl)
+		}
+
+		if err := c.Touch("session", 0); err != nil {
+			t.Errorf("%s: %v", driver, err)
+		}
+
+		if ttl, _ := c.TTL("session"); ttl != 0 {
+			t.Errorf("%s: expected no expiry, got %v", driver, ttl)
+		}
+
+		if x, _ := c.Get("session"); x != "data" {
+			t.Errorf("%s: touch changed the value to %v", driver, x)
+		}
+
+		_ = c.Forget("session")
+	}
+}
@@ -1,14 +1,18 @@
 package celeritas
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"net/rpc"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/CloudyKit/jet/v6"
@@ -16,9 +20,9 @@ import (
 	"github.com/dgraph-io/badger/v4"
 	"github.com/go-chi/chi/v5"
 	"github.com/gomodule/redigo/redis"
-	"github.com/joho/godotenv"
-	"github.com/robfig/cron/v3"
 	"github.com/s-petr/celeritas/cache"
+	"github.com/s-petr/celeritas/filesystems"
+	"github.com/s-petr/celeritas/filesystems/local"
 	"github.com/s-petr/celeritas/filesystems/minio"
 	"github.com/s-petr/celeritas/filesystems/s3"
 	"github.com/s-petr/celeritas/filesystems/sftp"
@@ -26,12 +30,14 @@ import (
 	"github.com/s-petr/celeritas/mailer"
 	"github.com/s-petr/celeritas/render"
 	"github.com/s-petr/celeritas/session"
+	"github.com/s-petr/celeritas/settings"
 )
 
 const version = "1.0.0"
 
 var myRedisCache *cache.RedisCache
 var myBadgerCache *cache.BadgerCache
+var myTieredCache *cache.TieredCache
 var redisPool *redis.Pool
 var badgerConn *badger.DB
 var maintenanceMode bool
@@ -49,16 +55,25 @@ type Celeritas struct {
 	DB            Database
 	JetViews      *jet.Set
 	config        config
+	Settings      *settings.Config
 	EncryptionKey string
 	Cache         cache.Cache
-	Scheduler     *cron.Cron
+	Locker        cache.Locker
+	Scheduler     *Scheduler
 	Mail          mailer.Mail
 	Server        Server
-	FileSystems   map[string]any
+	FileSystems   *filesystems.Registry
 	S3            s3.S3
 	SFTP          sftp.SFTP
 	WebDAV        webdav.WebDAV
 	Minio         minio.Minio
+	Local         local.Local
+
+	// CSRFFailureHandler, if set, answers requests which fail the CSRF check
+	CSRFFailureHandler http.Handler
+
+	browserOnce sync.Once
+	browser     *Browser
 }
 
 type Server struct {
@@ -69,13 +84,16 @@ type Server struct {
 }
 
 type config struct {
-	port        string
-	renderer    string
-	cookie      cookieConfig
-	sessionType string
-	database    databaseConfig
-	redis       redisConfig
-	upload      uploadConfig
+	port            string
+	renderer        string
+	cookie          cookieConfig
+	sessionType     string
+	database        databaseConfig
+	redis           redisConfig
+	upload          uploadConfig
+	cors            CORSOptions
+	securityHeaders SecurityHeaders
+	csrf            CSRFOptions
 }
 
 type uploadConfig struct {
@@ -83,7 +101,16 @@ type uploadConfig struct {
 	maxUploadSize    int64
 }
 
-func (c *Celeritas) New(rootPath string) error {
+// New sets up the application in rootPath from its .env and config files.
+// Options can replace or disable subsystems and retry connecting to the
+// database and Redis; a subsystem which fails to start is returned as an
+// error.
+func (c *Celeritas) New(rootPath string, opts ...Options) error {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
 	pathConfig := initPaths{
 		rootPath: rootPath,
 		folderNames: []string{"handlers", "migrations", "views",
@@ -100,122 +127,106 @@ func (c *Celeritas) New(rootPath string) error {
 		return err
 	}
 
-	// read .env
-	err = godotenv.Load(rootPath + "/.env")
+	// read .env and the config files
+	c.Settings, err = settings.Load(rootPath)
 	if err != nil {
 		return err
 	}
+	cfg := c.Settings
 
 	// create loggers
 	infoLog, errorLog := c.startLoggers()
 	c.InfoLog = infoLog
 	c.ErrorLog = errorLog
 
-	if os.Getenv("DATABASE_TYPE") != "" {
-		db, err := c.OpenDB(os.Getenv("DATABASE_TYPE"), c.BuildDSN())
-		if err != nil {
-			errorLog.Println(err)
-			os.Exit(1)
-		}
-		c.DB = Database{
-			DataType: os.Getenv("DATABASE_TYPE"),
-			Pool:     db,
-		}
-	}
-
-	scheduler := cron.New()
-	c.Scheduler = scheduler
-
-	if os.Getenv("CACHE") == "redis" {
-		myRedisCache = c.createRedisCache()
-		c.Cache = myRedisCache
-		redisPool = myRedisCache.Conn
-	} else if os.Getenv("CACHE") == "badger" {
-		myBadgerCache = c.createBadgerCache()
-		c.Cache = myBadgerCache
-		badgerConn = myBadgerCache.Conn
-
-		_, err = c.Scheduler.AddFunc("@daily", func() {
-			_ = myBadgerCache.Conn.RunValueLogGC(0.7)
-		})
-		if err != nil {
-			return err
-		}
-	}
-
-	c.Debug, err = strconv.ParseBool(os.Getenv("DEBUG"))
-	if err != nil {
-		c.Debug = true
-		c.ErrorLog.Println(errors.New("could not read debug mode setting, defaulting to true (enabled)"))
-	}
+	c.Debug = cfg.App.Debug
 	c.Version = version
 	c.RootPath = rootPath
-	c.Mail = c.createMailer()
-	c.Routes = c.routes().(*chi.Mux)
-
-	var maxUploadSize int64
-	if max, err := strconv.Atoi(os.Getenv("MAX_UPLOAD_SIZE")); err != nil {
-		maxUploadSize = 10 << 20
-	} else {
-		maxUploadSize = int64(max)
-	}
 
 	c.config = config{
-		port:     os.Getenv("PORT"),
-		renderer: os.Getenv("RENDERER"),
+		port:     port(cfg.App.Port),
+		renderer: cfg.App.Renderer,
 		cookie: cookieConfig{
-			name:     os.Getenv("COOKIE_NAME"),
-			lifetime: os.Getenv("COOKIE_LIFETIME"),
-			persist:  os.Getenv("COOKIE_PERSISTS"),
-			secure:   os.Getenv("COOKIE_SECURE"),
-			domain:   os.Getenv("COOKIE_DOMAIN"),
+			name:     cfg.Cookie.Name,
+			lifetime: strconv.Itoa(cfg.Cookie.Lifetime),
+			persist:  strconv.FormatBool(cfg.Cookie.Persist),
+			secure:   strconv.FormatBool(cfg.Cookie.Secure),
+			domain:   cfg.Cookie.Domain,
 		},
-		sessionType: os.Getenv("SESSION_TYPE"),
+		sessionType: cfg.Session.Type,
 		database: databaseConfig{
-			database: os.Getenv("DATABASE_TYPE"),
+			database: cfg.Database.Type,
 			dsn:      c.BuildDSN()},
 		redis: redisConfig{
-			host:     os.Getenv("REDIS_HOST"),
-			password: os.Getenv("REDIS_PASSWORD"),
-			prefix:   os.Getenv("REDIS_PREFIX"),
+			host:     cfg.Redis.Host,
+			password: cfg.Redis.Password,
+			prefix:   cfg.Redis.Prefix,
 		},
 		upload: uploadConfig{
-			allowedMimeTypes: strings.Split(os.Getenv("ALLOWED_FILETYPES"), ","),
-			maxUploadSize:    maxUploadSize,
+			allowedMimeTypes: cfg.Upload.AllowedFileTypes,
+			maxUploadSize:    cfg.Upload.MaxSize,
 		},
+		cors:            corsFromSettings(cfg.CORS),
+		securityHeaders: securityHeadersFromSettings(cfg.Headers),
+		csrf:            csrfFromSettings(cfg.CSRF),
 	}
 
-	secure := true
-	if strings.ToLower(os.Getenv("SECURE")) == "false" {
-		secure = false
+	c.Server = Server{
+		ServerName: cfg.App.ServerName,
+		Port:       port(cfg.App.Port),
+		Secure:     cfg.App.Secure,
+		URL:        cfg.App.ServerURL,
 	}
 
-	c.Server = Server{
-		ServerName: os.Getenv("SERVER_NAME"),
-		Port:       os.Getenv("PORT"),
-		Secure:     secure,
-		URL:        os.Getenv("SERVER_URL"),
+	// forget the connections made by an earlier call to New
+	myRedisCache, myBadgerCache, myTieredCache = nil, nil, nil
+	redisPool, badgerConn = nil, nil
+
+	// close whatever was connected to if a later step fails
+	started := false
+	defer func() {
+		if !started {
+			c.closeConnections(o)
+		}
+	}()
+
+	retry := c.retryOptions(o)
+
+	if err := c.setupDB(o, retry); err != nil {
+		return fmt.Errorf("database: %w", err)
 	}
 
-	sess := session.Session{
-		CookieLifetime: c.config.cookie.lifetime,
-		CookiePersist:  c.config.cookie.persist,
-		CookieName:     c.config.cookie.name,
-		CookieDomain:   c.config.cookie.domain,
-		SessionType:    c.config.sessionType,
-		DBPool:         c.DB.Pool,
+	c.Scheduler = NewScheduler(errorLog)
+
+	if err := c.setupCache(o, retry); err != nil {
+		return fmt.Errorf("cache: %w", err)
 	}
 
-	switch c.config.sessionType {
-	case "redis":
-		sess.RedisPool = myRedisCache.Conn
-	case "mysql", "mariadb", "postgres", "postgresql":
-		sess.DBPool = c.DB.Pool
+	c.Scheduler.Locker = c.Locker
+	c.Scheduler.History = c.Cache
+
+	if err := c.scheduleHealthChecks(); err != nil {
+		return fmt.Errorf("database: %w", err)
 	}
 
-	c.Session = sess.InitSession()
+	switch {
+	case o.Mailer != nil:
+		c.Mail = *o.Mailer
+		if c.Mail.Jobs == nil {
+			c.Mail.Jobs = make(chan mailer.Message, 20)
+			c.Mail.Results = make(chan mailer.Result, 20)
+		}
+	case o.DisableMailer:
+		c.Mail = disabledMailer()
+	default:
+		c.Mail = c.createMailer()
+	}
+
+	if err := c.setupSession(o, retry); err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
 
-	c.EncryptionKey = os.Getenv("KEY")
+	c.EncryptionKey = cfg.App.Key
 
 	if c.Debug {
 		c.JetViews = jet.NewSet(
@@ -230,10 +241,205 @@ func (c *Celeritas) New(rootPath string) error {
 
 	c.createRenderer()
 
-	c.FileSystems = c.createFileSystems()
+	switch {
+	case o.FileSystems != nil:
+		c.FileSystems = o.FileSystems
+	case !o.DisableFileSystems:
+		c.FileSystems, err = c.CreateFileSystems()
+		if err != nil {
+			return fmt.Errorf("file systems: %w", err)
+		}
+	}
+
+	// the middleware in routes reads the settings above
+	c.Routes = c.routes().(*chi.Mux)
+
+	if !o.DisableMailer {
+		go c.Mail.ListenForMail()
+	}
+
+	started = true
+	return nil
+}
+
+// closeConnections closes the database, Redis and Badger connections New
+// made, leaving those passed in Options open, and forgets them
+func (c *Celeritas) closeConnections(o Options) {
+	if o.DB == nil {
+		if c.DB.Pool != nil {
+			_ = c.DB.Pool.Close()
+		}
+		if c.DB.ReadPool != nil {
+			_ = c.DB.ReadPool.Close()
+		}
+		c.DB = Database{}
+	}
+
+	if o.Cache == nil {
+		c.Cache, c.Locker = nil, nil
+	}
+
+	if myTieredCache != nil {
+		_ = myTieredCache.Close()
+	}
+	if redisPool != nil {
+		_ = redisPool.Close()
+	}
+	if badgerConn != nil {
+		_ = badgerConn.Close()
+	}
+
+	myRedisCache, myBadgerCache, myTieredCache = nil, nil, nil
+	redisPool, badgerConn = nil, nil
+}
+
+// setupDB connects to DATABASE_TYPE, unless a database was given or the
+// database is disabled
+func (c *Celeritas) setupDB(o Options, retry RetryOptions) error {
+	dbType := c.Settings.Database.Type
+
+	switch {
+	case o.DB != nil:
+		c.DB = *o.DB
+		if c.DB.health == nil {
+			c.DB.health = &dbHealth{}
+		}
+		return nil
+	case o.DisableDB || dbType == "":
+		return nil
+	}
+
+	var db *sql.DB
+	err := c.retry("database", retry, func() error {
+		var err error
+		db, err = c.OpenDB(dbType, c.BuildDSN())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c.DB = Database{
+		DataType: dbType,
+		Pool:     db,
+		health:   &dbHealth{},
+	}
+
+	if dsn := c.BuildReadDSN(); dsn != "" {
+		err := c.retry("read replica", retry, func() error {
+			var err error
+			c.DB.ReadPool, err = c.OpenDB(dbType, dsn)
+			return err
+		})
+		if err != nil {
+			_ = db.Close()
+			return fmt.Errorf("read replica: %w", err)
+		}
+	}
+	return nil
+}
+
+// setupCache creates the CACHE driver, unless a cache was given or the
+// cache is disabled
+func (c *Celeritas) setupCache(o Options, retry RetryOptions) error {
+	switch {
+	case o.Cache != nil:
+		c.Cache = o.Cache
+		c.Locker, _ = o.Cache.(cache.Locker)
+		return nil
+	case o.DisableCache:
+		return nil
+	}
+
+	cacheCodec, err := cache.CodecByName(c.Settings.Cache.Codec)
+	if err != nil {
+		return err
+	}
+
+	switch c.Settings.Cache.Driver {
+	case "redis":
+		pool, err := c.connectRedis(retry)
+		if err != nil {
+			return err
+		}
+		myRedisCache = c.createRedisCache(pool)
+		myRedisCache.Codec = cacheCodec
+		c.Cache = myRedisCache
+		c.Locker = myRedisCache
+	case "tiered":
+		pool, err := c.connectRedis(retry)
+		if err != nil {
+			return err
+		}
+		myRedisCache = c.createRedisCache(pool)
+		myRedisCache.Codec = cacheCodec
+
+		myTieredCache = cache.NewTieredCache(c.createMemoryCache(), myRedisCache)
+		if err := myTieredCache.Subscribe(); err != nil {
+			return err
+		}
+		c.Cache = myTieredCache
+		c.Locker = myTieredCache
+	case "badger":
+		myBadgerCache, err = c.createBadgerCache()
+		if err != nil {
+			return err
+		}
+		myBadgerCache.Codec = cacheCodec
+		c.Cache = myBadgerCache
+		c.Locker = myBadgerCache
+		badgerConn = myBadgerCache.Conn
+
+		_, err = c.Scheduler.AddFunc("@daily", func() {
+			_ = myBadgerCache.Conn.RunValueLogGC(0.7)
+		})
+		if err != nil {
+			return err
+		}
+	default:
+		memoryCache := c.createMemoryCache()
+		memoryCache.Codec = cacheCodec
+		c.Cache = memoryCache
+		c.Locker = memoryCache
+	}
+
+	return nil
+}
+
+// setupSession creates the SESSION_TYPE session manager, unless one was
+// given or sessions are disabled
+func (c *Celeritas) setupSession(o Options, retry RetryOptions) error {
+	switch {
+	case o.Session != nil:
+		c.Session = o.Session
+		return nil
+	case o.DisableSession:
+		return nil
+	}
+
+	sess := session.Session{
+		CookieLifetime: c.config.cookie.lifetime,
+		CookiePersist:  c.config.cookie.persist,
+		CookieName:     c.config.cookie.name,
+		CookieDomain:   c.config.cookie.domain,
+		SessionType:    c.config.sessionType,
+		DBPool:         c.DB.Pool,
+	}
 
-	go c.Mail.ListenForMail()
+	switch c.config.sessionType {
+	case "redis":
+		pool, err := c.connectRedis(retry)
+		if err != nil {
+			return err
+		}
+		sess.RedisPool = pool
+	case "mysql", "mariadb", "postgres", "postgresql", "sqlite", "sqlite3":
+		if c.DB.Pool == nil {
+			return fmt.Errorf("SESSION_TYPE %s needs a database", c.config.sessionType)
+		}
+	}
 
+	c.Session = sess.InitSession()
 	return nil
 }
 
@@ -275,44 +481,79 @@ func (c *Celeritas) createRenderer() {
 }
 
 func (c *Celeritas) createMailer() mailer.Mail {
-	port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
-	if err != nil {
-		c.ErrorLog.Printf("invalid SMTP port provided (%s)", os.Getenv("SMTP_PORT"))
-	}
+	cfg := c.Settings.Mail
 
 	m := mailer.Mail{
-		Domain:      os.Getenv("MAIL_DOMAIN"),
+		Domain:      cfg.Domain,
 		Templates:   c.RootPath + "/mail",
-		Host:        os.Getenv("SMTP_HOST"),
-		Port:        port,
-		Username:    os.Getenv("SMTP_USERNAME"),
-		Password:    os.Getenv("SMTP_PASSWORD"),
-		Encryption:  os.Getenv("SMTP_ENCRYPTION"),
-		FromName:    os.Getenv("FROM_NAME"),
-		FromAddress: os.Getenv("FROM_ADDRESS"),
+		Host:        cfg.SMTPHost,
+		Port:        cfg.SMTPPort,
+		Username:    cfg.SMTPUsername,
+		Password:    cfg.SMTPPassword,
+		Encryption:  cfg.SMTPEncryption,
+		FromName:    cfg.FromName,
+		FromAddress: cfg.FromAddress,
 		Jobs:        make(chan mailer.Message, 20),
 		Results:     make(chan mailer.Result, 20),
-		API:         os.Getenv("MAILER_API"),
-		APIKey:      os.Getenv("MAILER_KEY"),
-		APIURL:      os.Getenv("MAILER_URL"),
+		API:         cfg.API,
+		APIKey:      cfg.APIKey,
+		APIURL:      cfg.APIURL,
 	}
 
 	return m
 }
 
-func (c *Celeritas) createRedisCache() *cache.RedisCache {
+// cachePrefix namespaces cache keys, from CACHE_PREFIX or else REDIS_PREFIX
+func (c *Celeritas) cachePrefix() string {
+	if prefix := c.Settings.Cache.Prefix; prefix != "" {
+		return prefix
+	}
+	return c.Settings.Redis.Prefix
+}
+
+func (c *Celeritas) createRedisCache(pool *redis.Pool) *cache.RedisCache {
 	cacheClient := cache.RedisCache{
-		Conn:   c.createRedisPool(),
-		Prefix: c.config.redis.prefix,
+		Conn:   pool,
+		Prefix: c.cachePrefix(),
 	}
 	return &cacheClient
 }
 
-func (c *Celeritas) createBadgerCache() *cache.BadgerCache {
+// connectRedis returns the Redis pool shared by the cache and sessions,
+// creating it and waiting for Redis to answer the first time it is called
+func (c *Celeritas) connectRedis(retry RetryOptions) (*redis.Pool, error) {
+	if redisPool != nil {
+		return redisPool, nil
+	}
+
+	pool := c.createRedisPool()
+	err := c.retry("redis", retry, func() error {
+		return pingRedis(pool)
+	})
+	if err != nil {
+		_ = pool.Close()
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+
+	redisPool = pool
+	return pool, nil
+}
+
+func (c *Celeritas) createMemoryCache() *cache.MemoryCache {
+	return cache.NewMemoryCache(c.Settings.Cache.MemorySize)
+}
+
+func (c *Celeritas) createBadgerCache() (*cache.BadgerCache, error) {
+	conn, err := c.createBadgerConn()
+	if err != nil {
+		return nil, err
+	}
+
 	cacheClient := cache.BadgerCache{
-		Conn: c.createBadgerConn(),
+		Conn:   conn,
+		Prefix: c.cachePrefix(),
 	}
-	return &cacheClient
+	return &cacheClient, nil
 }
 
 func (c *Celeritas) createRedisPool() *redis.Pool {
@@ -334,106 +575,161 @@ func (c *Celeritas) createRedisPool() *redis.Pool {
 	}
 }
 
-func (c *Celeritas) createBadgerConn() *badger.DB {
+func (c *Celeritas) createBadgerConn() (*badger.DB, error) {
 	db, err := badger.Open(badger.DefaultOptions(c.RootPath + "/tmp/badger"))
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("badger: %w", err)
 	}
-	return db
+	return db, nil
 }
 
+// BuildDSN returns the DSN of the database configured by the DATABASE_
+// settings
 func (c *Celeritas) BuildDSN() string {
+	if c.Settings == nil {
+		return ""
+	}
+	return c.buildDSN(c.Settings.Database)
+}
+
+// BuildReadDSN returns the DSN of the read replica at DATABASE_READ_HOST, or
+// "" if there is none. Settings without a DATABASE_READ_ value are the
+// primary's.
+func (c *Celeritas) BuildReadDSN() string {
+	if c.Settings == nil || c.Settings.Database.ReadHost == "" {
+		return ""
+	}
+
+	db := c.Settings.Database
+	db.Host = db.ReadHost
+	if db.ReadPort != 0 {
+		db.Port = db.ReadPort
+	}
+	if db.ReadUser != "" {
+		db.User = db.ReadUser
+	}
+	if db.ReadPass != "" {
+		db.Pass = db.ReadPass
+	}
+	if db.ReadName != "" {
+		db.Name = db.ReadName
+	}
+	return c.buildDSN(db)
+}
+
+// buildDSN builds the DSN for the database db
+func (c *Celeritas) buildDSN(db settings.Database) string {
 	var dsn string
 
-	switch os.Getenv("DATABASE_TYPE") {
-	case "postgres", "postgresql":
-		if os.Getenv("DATABASE_PASS") != "" {
-			dsn = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-				os.Getenv("DATABASE_USER"),
-				os.Getenv("DATABASE_PASS"),
-				os.Getenv("DATABASE_HOST"),
-				os.Getenv("DATABASE_PORT"),
-				os.Getenv("DATABASE_NAME"),
-				os.Getenv("DATABASE_SSL_MODE"))
+	host := db.Host
+	if db.Port != 0 {
+		host = net.JoinHostPort(db.Host, strconv.Itoa(db.Port))
+	}
+
+	switch db.Type {
+	case "postgres", "postgresql", "pgx":
+		if db.Pass != "" {
+			dsn = fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s",
+				db.User, db.Pass, host, db.Name, db.SSLMode)
 		} else {
-			dsn = fmt.Sprintf("postgres://%s@%s:%s/%s?sslmode=%s",
-				os.Getenv("DATABASE_USER"),
-				os.Getenv("DATABASE_HOST"),
-				os.Getenv("DATABASE_PORT"),
-				os.Getenv("DATABASE_NAME"),
-				os.Getenv("DATABASE_SSL_MODE"))
+			dsn = fmt.Sprintf("postgres://%s@%s/%s?sslmode=%s",
+				db.User, host, db.Name, db.SSLMode)
 		}
-	case "mysql":
-		dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-			os.Getenv("DATABASE_USER"),
-			os.Getenv("DATABASE_PASS"),
-			os.Getenv("DATABASE_HOST"),
-			os.Getenv("DATABASE_PORT"),
-			os.Getenv("DATABASE_NAME"))
+	case "mysql", "mariadb":
+		dsn = fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true",
+			db.User, db.Pass, host, db.Name)
+	case "sqlite", "sqlite3":
+		dsn = fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL",
+			c.sqlitePath(db.Name))
 	default:
 	}
 	return dsn
 }
 
-func (c *Celeritas) createFileSystems() map[string]any {
-	fileSystems := make(map[string]any)
+// port formats a port setting, which is empty if it was not set
+func port(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
 
-	if os.Getenv("MINIO_SECRET") != "" {
-		useSSL := false
-		if strings.ToLower(os.Getenv("MINIO_USESSL")) == "true" {
-			useSSL = true
-		}
+// sqlitePath returns the path of the SQLite database file name, relative to
+// the application's root
+func (c *Celeritas) sqlitePath(name string) string {
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(c.RootPath, name)
+	}
+	return name
+}
 
-		minio := minio.Minio{
-			Endpoint: os.Getenv("MINIO_ENDPOINT"),
-			Key:      os.Getenv("MINIO_KEY"),
-			Secret:   os.Getenv("MINIO_SECRET"),
-			UseSSL:   useSSL,
-			Region:   os.Getenv("MINIO_REGION"),
-			Bucket:   os.Getenv("MINIO_BUCKET"),
-		}
+// CreateFileSystems configures every file system that has settings in .env.
+// The S3_, MINIO_, SFTP_, WEBDAV_ and LOCAL_ settings create disks named
+// after their driver; further disks are listed in FILESYSTEMS and configured
+// with the same settings under an FS_<NAME>_ prefix, for example
+//
+//	FILESYSTEMS=avatars,backups
+//	FS_AVATARS_DRIVER=s3
+//	FS_AVATARS_BUCKET=avatars
+//	FS_BACKUPS_DRIVER=sftp
+//	FS_BACKUPS_HOST=backups.example.com
+//
+// FILESYSTEM_DEFAULT names the disk used when no file system is given, such
+// as by UploadFile. Without it paths are used as given on the local disk.
+func (c *Celeritas) CreateFileSystems() (*filesystems.Registry, error) {
+	fileSystems := filesystems.NewRegistry()
 
-		fileSystems["MINIO"] = minio
-		c.Minio = minio
+	if os.Getenv("MINIO_SECRET") != "" {
+		c.Minio = minioFromEnv("MINIO_")
+		fileSystems.Register("minio", &c.Minio)
 	}
 
 	if os.Getenv("SFTP_HOST") != "" {
-		sftp := sftp.SFTP{
-			Host: os.Getenv("SFTP_HOST"),
-			User: os.Getenv("SFTP_USER"),
-			Pass: os.Getenv("SFTP_PASS"),
-			Port: os.Getenv("SFTP_PORT"),
-		}
-		fileSystems["SFTP"] = sftp
-		c.SFTP = sftp
+		c.SFTP = sftpFromEnv("SFTP_")
+		fileSystems.Register("sftp", &c.SFTP)
 	}
 
 	if os.Getenv("WEBDAV_HOST") != "" {
-		webDAV := webdav.WebDAV{
-			Host: os.Getenv("WEBDAV_HOST"),
-			User: os.Getenv("WEBDAV_USER"),
-			Pass: os.Getenv("WEBDAV_PASS"),
-		}
-		fileSystems["WEBDAV"] = webDAV
-		c.WebDAV = webDAV
+		c.WebDAV = webDAVFromEnv("WEBDAV_")
+		fileSystems.Register("webdav", &c.WebDAV)
+	}
+
+	if os.Getenv("LOCAL_ROOT") != "" {
+		c.Local = c.localFromEnv("LOCAL_")
+		fileSystems.Register("local", &c.Local)
 	}
 
 	if os.Getenv("S3_KEY") != "" {
-		s3 := s3.S3{
-			Key:      os.Getenv("S3_KEY"),
-			Secret:   os.Getenv("S3_SECRET"),
-			Region:   os.Getenv("S3_REGION"),
-			Endpoint: os.Getenv("S3_ENDPOINT"),
-			Bucket:   os.Getenv("S3_BUCKET"),
+		c.S3 = s3FromEnv("S3_")
+		fileSystems.Register("s3", &c.S3)
+	}
+
+	for _, name := range strings.Split(os.Getenv("FILESYSTEMS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
 		}
-		fileSystems["S3"] = s3
-		c.S3 = s3
+
+		prefix := envPrefix("FS_" + strings.ToUpper(name) + "_")
+		fs, err := c.diskFromEnv(prefix.get("DRIVER"), prefix)
+		if err != nil {
+			return nil, fmt.Errorf("file system %s: %w", name, err)
+		}
+		fileSystems.Register(name, fs)
 	}
 
-	return fileSystems
+	if defaultDisk := os.Getenv("FILESYSTEM_DEFAULT"); defaultDisk != "" {
+		if err := fileSystems.SetDefault(defaultDisk); err != nil {
+			return nil, err
+		}
+	}
+
+	return fileSystems, nil
 }
 
-type RPCServer struct{}
+type RPCServer struct {
+	app *Celeritas
+}
 
 func (r *RPCServer) MaintenanceMode(inMaintenanceMode bool, resp *string) error {
 	if inMaintenanceMode {
@@ -446,18 +742,38 @@ func (r *RPCServer) MaintenanceMode(inMaintenanceMode bool, resp *string) error
 	return nil
 }
 
+func (r *RPCServer) Jobs(_ bool, resp *[]JobStatus) error {
+	if r.app.Scheduler == nil {
+		return errors.New("no scheduler")
+	}
+	*resp = r.app.Scheduler.Jobs()
+	return nil
+}
+
+func (r *RPCServer) RunJob(name string, resp *string) error {
+	if r.app.Scheduler == nil {
+		return errors.New("no scheduler")
+	}
+	if err := r.app.Scheduler.RunNow(name); err != nil {
+		return err
+	}
+	*resp = fmt.Sprintf("Job %s started", name)
+	return nil
+}
+
 func (c *Celeritas) listenRPC() {
-	if os.Getenv("RPC_PORT") == "" {
+	rpcPort := port(c.Settings.App.RPCPort)
+	if rpcPort == "" {
 		return
 	}
 
-	c.InfoLog.Println("Starting RPC server on port", os.Getenv("RPC_PORT"))
-	if err := rpc.Register(new(RPCServer)); err != nil {
+	c.InfoLog.Println("Starting RPC server on port", rpcPort)
+	if err := rpc.Register(&RPCServer{app: c}); err != nil {
 		c.ErrorLog.Println(err)
 		return
 	}
 
-	listen, err := net.Listen("tcp", "127.0.0.1:"+os.Getenv("RPC_PORT"))
+	listen, err := net.Listen("tcp", "127.0.0.1:"+rpcPort)
 	if err != nil {
 		c.ErrorLog.Println(err)
 		return
@@ -0,0 +1,251 @@
+// Package celeritastest boots a Celeritas application for tests. The
+// application gets an in-memory cache, cookie sessions, an in-memory SQLite
+// database (or any database the test passes in, such as one wrapped by
+// txdb), a mailbox capturing the mail it sends, local disks in temporary
+// directories and an httptest server, and the App returned has helpers for
+// logging in, reading flash messages and checking which templates were
+// rendered. Browser tests open pages of the test server with
+// app.Browser().Open.
+//
+//	func TestHome(t *testing.T) {
+//		app := celeritastest.New(t, celeritastest.Options{RootPath: ".."})
+//		app.Routes = routes(app.Celeritas)
+//
+//		app.LoginAs(1)
+//		app.Get("/")
+//		app.AssertRendered("home")
+//	}
+package celeritastest
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/s-petr/celeritas"
+	"github.com/s-petr/celeritas/filesystems"
+	"github.com/s-petr/celeritas/filesystems/local"
+	"github.com/s-petr/celeritas/mailer"
+	"github.com/s-petr/celeritas/render"
+)
+
+// Options configure the application New boots
+type Options struct {
+	// RootPath is the root of the application, with its views and mail
+	// templates; a temporary directory if empty
+	RootPath string
+	// Env holds settings for the test, taking precedence over .env and the
+	// defaults set by New
+	Env map[string]string
+	// DB, if set, is used instead of an in-memory SQLite database, for
+	// example a database opened with the txdb driver so that each test runs
+	// in a transaction which is rolled back
+	DB *sql.DB
+	// DataType is the DATABASE_TYPE of DB, postgres if empty
+	DataType string
+	// Disks names the disks to create, each a local disk in a temporary
+	// directory; the first is the default. If empty a disk named local is
+	// created.
+	Disks []string
+}
+
+// App is a Celeritas application served by an httptest server
+type App struct {
+	*celeritas.Celeritas
+	// Server serves the application's Routes
+	Server *httptest.Server
+	// Client keeps the cookies the application sets and does not follow
+	// redirects, so that tests can check them
+	Client *http.Client
+	// Mailbox holds the mail the application has sent
+	Mailbox *Mailbox
+
+	t     testing.TB
+	mu    sync.Mutex
+	pages []Page
+}
+
+// Page is a page the application rendered
+type Page struct {
+	Path     string
+	Template string
+	Data     *render.TemplateData
+}
+
+// env are the settings New overrides, so that the application runs
+// without outside services
+var env = map[string]string{
+	"CACHE":            "memory",
+	"SESSION_TYPE":     "cookie",
+	"DATABASE_TYPE":    "",
+	"COOKIE_SECURE":    "false",
+	"SECURE":           "false",
+	"STARTUP_ATTEMPTS": "1",
+}
+
+var databases atomic.Int64
+
+// New boots the application, stopping the test if it fails to start. The
+// application's resources are released, and the environment its settings
+// were loaded into is restored, when the test ends.
+func New(t testing.TB, opts ...Options) *App {
+	t.Helper()
+
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	root := o.RootPath
+	if root == "" {
+		root = t.TempDir()
+	}
+
+	restoreEnv(t)
+
+	for name, value := range env {
+		if _, ok := o.Env[name]; !ok {
+			t.Setenv(name, value)
+		}
+	}
+	for name, value := range o.Env {
+		t.Setenv(name, value)
+	}
+
+	a := &App{
+		Celeritas: &celeritas.Celeritas{},
+		Mailbox:   &Mailbox{},
+		t:         t,
+	}
+
+	db, dataType := o.DB, o.DataType
+	if db == nil {
+		db, dataType = openSQLite(t), "sqlite"
+	} else if dataType == "" {
+		dataType = "postgres"
+	}
+
+	err := a.New(root, celeritas.Options{
+		DB: &celeritas.Database{DataType: dataType, Pool: db},
+		Mailer: &mailer.Mail{
+			Templates:   filepath.Join(root, "mail"),
+			FromAddress: "test@example.com",
+			Transport:   a.Mailbox,
+		},
+		FileSystems: disks(t, o.Disks),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.Render.OnPage = a.recordPage
+
+	a.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// read Routes for every request, so that tests can replace them
+		a.Routes.ServeHTTP(w, r)
+	}))
+	t.Cleanup(a.Server.Close)
+
+	// pages opened in the browser are served by the test server; the
+	// browser itself only starts if a test opens one
+	a.Browser().BaseURL = a.Server.URL
+	t.Cleanup(func() { _ = a.Browser().Close() })
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.Client = a.Server.Client()
+	a.Client.Jar = jar
+	a.Client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return a
+}
+
+// restoreEnv puts the environment back the way it is now when the test
+// ends, removing the settings which loading the application's .env and
+// config files exported
+func restoreEnv(t testing.TB) {
+	saved := make(map[string]string)
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		saved[name] = value
+	}
+
+	t.Cleanup(func() {
+		for _, kv := range os.Environ() {
+			if name, _, _ := strings.Cut(kv, "="); name != "" {
+				if _, ok := saved[name]; !ok {
+					_ = os.Unsetenv(name)
+				}
+			}
+		}
+		for name, value := range saved {
+			if os.Getenv(name) != value {
+				_ = os.Setenv(name, value)
+			}
+		}
+	})
+}
+
+func openSQLite(t testing.TB) *sql.DB {
+	t.Helper()
+
+	// a named in-memory database lives as long as one of its connections,
+	// and is shared by all of them
+	dsn := fmt.Sprintf("file:celeritastest%d?mode=memory&cache=shared", databases.Add(1))
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func disks(t testing.TB, names []string) *filesystems.Registry {
+	t.Helper()
+
+	if len(names) == 0 {
+		names = []string{"local"}
+	}
+
+	registry := filesystems.NewRegistry()
+	for _, name := range names {
+		registry.Register(name, &local.Local{Root: t.TempDir()})
+	}
+
+	if err := registry.SetDefault(names[0]); err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+// URL returns the address of path on the test server
+func (a *App) URL(path string) string {
+	return a.Server.URL + path
+}
+
+func (a *App) serverURL() *url.URL {
+	u, err := url.Parse(a.Server.URL)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return u
+}
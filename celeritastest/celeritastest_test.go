@@ -0,0 +1,123 @@
+package celeritastest
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	app := newTestApp(t)
+
+	if app.Settings.Cache.Driver != "memory" || app.Settings.Session.Type != "cookie" {
+		t.Errorf("expected the memory cache and cookie sessions, got %s and %s",
+			app.Settings.Cache.Driver, app.Settings.Session.Type)
+	}
+
+	if _, err := app.DB.Pool.Exec("create table users (id integer primary key, name text)"); err != nil {
+		t.Fatal(err)
+	}
+
+	disk, err := app.DefaultFileSystem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := disk.Exists("missing.txt"); err != nil || ok {
+		t.Error("expected an empty disk")
+	}
+}
+
+func TestNew_RestoresEnv(t *testing.T) {
+	if _, ok := os.LookupEnv("APP_NAME"); ok {
+		t.Skip("APP_NAME is set outside the test")
+	}
+
+	t.Run("app", func(t *testing.T) {
+		app := newTestApp(t)
+		if app.Settings.App.Name != "testapp" {
+			t.Errorf("expected APP_NAME from .env, got %q", app.Settings.App.Name)
+		}
+	})
+
+	if name, ok := os.LookupEnv("APP_NAME"); ok {
+		t.Errorf("expected APP_NAME from .env to be removed after the test, got %q", name)
+	}
+}
+
+func TestApp_Login(t *testing.T) {
+	app := newTestApp(t)
+
+	if resp := app.Get("/me"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 before logging in, got %d", resp.StatusCode)
+	}
+
+	app.LoginAs(7)
+	resp := app.Get("/me")
+	if resp.StatusCode != http.StatusOK || Body(resp) != "logged in" {
+		t.Errorf("expected to be logged in, got %d", resp.StatusCode)
+	}
+	if id, _ := app.SessionValue("userID").(int); id != 7 {
+		t.Errorf("expected user 7 in the session, got %v", app.SessionValue("userID"))
+	}
+
+	app.Logout()
+	if resp := app.Get("/me"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 after logging out, got %d", resp.StatusCode)
+	}
+}
+
+func TestApp_FlashAndRender(t *testing.T) {
+	app := newTestApp(t)
+
+	resp := app.PostForm("/save", url.Values{"name": {"draft"}})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d", resp.StatusCode)
+	}
+	app.AssertFlash("Saved draft")
+
+	resp = app.Get(resp.Header.Get("Location"))
+	td := app.AssertRendered("home")
+	if td == nil || td.Flash != "Saved draft" {
+		t.Error("expected the flash message in the template data")
+	}
+	if !strings.Contains(Body(resp), "Saved draft") {
+		t.Error("expected the flash message on the page")
+	}
+	app.AssertFlash("")
+}
+
+func TestApp_CSRF(t *testing.T) {
+	app := newTestApp(t)
+
+	resp := app.PostForm("/save", url.Values{"csrf_token": {"forged"}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a forged token to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestMailbox(t *testing.T) {
+	app := newTestApp(t)
+
+	resp := app.PostForm("/welcome", url.Values{"email": {"ann@example.com"}, "name": {"Ann"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the mail to be sent, got %d: %s", resp.StatusCode, Body(resp))
+	}
+
+	email, ok := app.Mailbox.Last()
+	if !ok {
+		t.Fatal("expected a message in the mailbox")
+	}
+	if email.Subject != "Welcome" || !strings.Contains(email.HTML, "Welcome Ann") || email.Plain != "Welcome Ann" {
+		t.Errorf("unexpected message %+v", email)
+	}
+	if len(app.Mailbox.To("bob@example.com")) != 0 {
+		t.Error("expected no mail for bob")
+	}
+
+	app.Mailbox.Reset()
+	if len(app.Mailbox.Emails()) != 0 {
+		t.Error("expected Reset to empty the mailbox")
+	}
+}
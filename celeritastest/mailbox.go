@@ -0,0 +1,64 @@
+package celeritastest
+
+import (
+	"sync"
+
+	"github.com/s-petr/celeritas/mailer"
+)
+
+// Email is a message the application sent, with its rendered bodies
+type Email struct {
+	mailer.Message
+	HTML  string
+	Plain string
+}
+
+// Mailbox is a mailer.Transport keeping every message instead of sending it
+type Mailbox struct {
+	mu     sync.Mutex
+	emails []Email
+}
+
+func (m *Mailbox) Deliver(msg mailer.Message, html, plain string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.emails = append(m.emails, Email{Message: msg, HTML: html, Plain: plain})
+	return nil
+}
+
+// Emails returns the messages delivered so far, oldest first
+func (m *Mailbox) Emails() []Email {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Email(nil), m.emails...)
+}
+
+// To returns the messages delivered to address
+func (m *Mailbox) To(address string) []Email {
+	var emails []Email
+	for _, email := range m.Emails() {
+		if email.To == address {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// Last returns the most recent message, and false if there is none
+func (m *Mailbox) Last() (Email, bool) {
+	emails := m.Emails()
+	if len(emails) == 0 {
+		return Email{}, false
+	}
+	return emails[len(emails)-1], true
+}
+
+// Reset empties the mailbox
+func (m *Mailbox) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.emails = nil
+}
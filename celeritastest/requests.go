@@ -0,0 +1,234 @@
+package celeritastest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/justinas/nosurf"
+	"github.com/s-petr/celeritas/render"
+)
+
+// Do sends r to the application with the client's cookies. The body of the
+// response has been read, so it need not be closed.
+func (a *App) Do(r *http.Request) *http.Response {
+	a.t.Helper()
+
+	resp, err := a.Client.Do(r)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	return resp
+}
+
+// Get requests path from the application
+func (a *App) Get(path string) *http.Response {
+	a.t.Helper()
+
+	r, err := http.NewRequest(http.MethodGet, a.URL(path), nil)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.Do(r)
+}
+
+// PostForm posts form to path, adding a CSRF token if form has none
+func (a *App) PostForm(path string, form url.Values) *http.Response {
+	a.t.Helper()
+
+	if form == nil {
+		form = url.Values{}
+	}
+	if form.Get(nosurf.FormFieldName) == "" {
+		form.Set(nosurf.FormFieldName, a.CSRFToken())
+	}
+
+	r, err := http.NewRequest(http.MethodPost, a.URL(path), strings.NewReader(form.Encode()))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return a.Do(r)
+}
+
+// Body returns the body of a response from Do, Get or PostForm
+func Body(resp *http.Response) string {
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return string(body)
+}
+
+// CSRFToken returns a token which passes the CSRF check for the client,
+// as a form field or in the X-CSRF-Token header
+func (a *App) CSRFToken() string {
+	a.t.Helper()
+
+	cookie := a.cookie(nosurf.CookieName)
+	if cookie == nil {
+		// any request gets the client a token
+		a.Get("/")
+		if cookie = a.cookie(nosurf.CookieName); cookie == nil {
+			a.t.Fatal("the application did not set a CSRF cookie")
+		}
+	}
+
+	real, err := base64.StdEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	// mask the token the way nosurf does: a one time pad followed by the
+	// token XORed with it
+	masked := make([]byte, 2*len(real))
+	if _, err := rand.Read(masked[:len(real)]); err != nil {
+		a.t.Fatal(err)
+	}
+	for i := range real {
+		masked[len(real)+i] = real[i] ^ masked[i]
+	}
+
+	return base64.StdEncoding.EncodeToString(masked)
+}
+
+func (a *App) cookie(name string) *http.Cookie {
+	for _, cookie := range a.Client.Jar.Cookies(a.serverURL()) {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// LoginAs starts a session for the client with userID logged in, the way
+// the auth handlers created by make auth do
+func (a *App) LoginAs(userID any) {
+	a.t.Helper()
+	a.PutSession("userID", userID)
+}
+
+// Logout ends the client's session
+func (a *App) Logout() {
+	a.t.Helper()
+
+	ctx := a.sessionContext()
+	if err := a.Session.Destroy(ctx); err != nil {
+		a.t.Fatal(err)
+	}
+	a.commitSession(ctx)
+}
+
+// PutSession stores value under key in the client's session
+func (a *App) PutSession(key string, value any) {
+	a.t.Helper()
+
+	ctx := a.sessionContext()
+	a.Session.Put(ctx, key, value)
+	a.commitSession(ctx)
+}
+
+// SessionValue returns the value under key in the client's session
+func (a *App) SessionValue(key string) any {
+	a.t.Helper()
+	return a.Session.Get(a.sessionContext(), key)
+}
+
+// Flash returns the flash message waiting in the client's session, without
+// removing it
+func (a *App) Flash() string {
+	a.t.Helper()
+	return a.Session.GetString(a.sessionContext(), "flash")
+}
+
+// AssertFlash fails the test if the flash message waiting in the client's
+// session is not want
+func (a *App) AssertFlash(want string) {
+	a.t.Helper()
+
+	if got := a.Flash(); got != want {
+		a.t.Errorf("expected flash message %q, got %q", want, got)
+	}
+}
+
+// sessionContext loads the client's session into a context
+func (a *App) sessionContext() context.Context {
+	a.t.Helper()
+
+	if a.Session == nil {
+		a.t.Fatal("the application has no sessions")
+	}
+
+	token := ""
+	if cookie := a.cookie(a.Session.Cookie.Name); cookie != nil {
+		token = cookie.Value
+	}
+
+	ctx, err := a.Session.Load(context.Background(), token)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return ctx
+}
+
+// commitSession saves the session in ctx and hands its cookie to the client
+func (a *App) commitSession(ctx context.Context) {
+	a.t.Helper()
+
+	token, expiry, err := a.Session.Commit(ctx)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	a.Client.Jar.SetCookies(a.serverURL(), []*http.Cookie{{
+		Name:    a.Session.Cookie.Name,
+		Value:   token,
+		Path:    "/",
+		Expires: expiry,
+	}})
+}
+
+func (a *App) recordPage(r *http.Request, templateName string, td *render.TemplateData) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pages = append(a.pages, Page{Path: r.URL.Path, Template: templateName, Data: td})
+}
+
+// Pages returns the pages rendered so far, oldest first
+func (a *App) Pages() []Page {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]Page(nil), a.pages...)
+}
+
+// AssertRendered fails the test if the last page rendered was not made
+// from templateName, and returns the data it was rendered with
+func (a *App) AssertRendered(templateName string) *render.TemplateData {
+	a.t.Helper()
+
+	pages := a.Pages()
+	if len(pages) == 0 {
+		a.t.Errorf("expected %s to be rendered, but no page was", templateName)
+		return nil
+	}
+
+	last := pages[len(pages)-1]
+	if last.Template != templateName {
+		a.t.Errorf("expected %s to be rendered, got %s", templateName, last.Template)
+	}
+	return last.Data
+}
@@ -0,0 +1,76 @@
+package celeritastest
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/s-petr/celeritas"
+	"github.com/s-petr/celeritas/mailer"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
+
+var appFiles = map[string]string{
+	".env":                   "APP_NAME=testapp\nRENDERER=jet\nSESSION_TYPE=redis\nCACHE=redis\n",
+	"views/home.jet":         "<p>{{ .Flash }}</p>",
+	"mail/welcome.html.tmpl": `{{define "body"}}<p>Welcome {{.}}</p>{{end}}`,
+	"mail/welcome.text.tmpl": `{{define "body"}}Welcome {{.}}{{end}}`,
+}
+
+// newTestApp boots an application with a few routes, from a root holding
+// appFiles
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, contents := range appFiles {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := New(t, Options{RootPath: root})
+	addRoutes(app.Celeritas)
+	return app
+}
+
+func addRoutes(c *celeritas.Celeritas) {
+	c.Routes.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		if err := c.Render.Page(w, r, "home", nil, nil); err != nil {
+			c.ErrorLog.Println(err)
+		}
+	})
+
+	c.Routes.Get("/me", func(w http.ResponseWriter, r *http.Request) {
+		if !c.Session.Exists(r.Context(), "userID") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("logged in"))
+	})
+
+	c.Routes.Post("/save", func(w http.ResponseWriter, r *http.Request) {
+		c.Session.Put(r.Context(), "flash", "Saved "+r.PostFormValue("name"))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	})
+
+	c.Routes.Post("/welcome", func(w http.ResponseWriter, r *http.Request) {
+		c.Mail.Jobs <- mailer.Message{
+			To:       r.PostFormValue("email"),
+			Subject:  "Welcome",
+			Template: "welcome",
+			Data:     r.PostFormValue("name"),
+		}
+		if res := <-c.Mail.Results; res.Error != nil {
+			http.Error(w, res.Error.Error(), http.StatusInternalServerError)
+		}
+	})
+}
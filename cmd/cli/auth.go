@@ -5,7 +5,7 @@ import (
 )
 
 func doAuth() error {
-	dbType := cel.DB.DataType
+	dbType := templateDBType()
 
 	tx, err := cel.PopConnect()
 	if err != nil {
@@ -33,6 +33,15 @@ func doAuth() error {
 	DROP TABLE IF EXISTS remember_tokens CASCADE;
 	`
 
+	if dbType == "sqlite" {
+		// SQLite has no CASCADE, so drop the tables referring to users first
+		stmt = `
+	DROP TABLE IF EXISTS tokens;
+	DROP TABLE IF EXISTS remember_tokens;
+	DROP TABLE IF EXISTS users;
+	`
+	}
+
 	downBytes := []byte(stmt)
 
 	if err := cel.CreatePopMigration(upBytes, downBytes, "auth", "sql"); err != nil {
@@ -81,6 +90,11 @@ func doAuth() error {
 		exitGracefully(err)
 	}
 
+	if err := copyFileFromTemplate("templates/middleware/throttle.go.txt",
+		cel.RootPath+"/middleware/throttle.go"); err != nil {
+		exitGracefully(err)
+	}
+
 	if err := copyFileFromTemplate("templates/handlers/auth-handlers.go.txt",
 		cel.RootPath+"/handlers/auth-handlers.go"); err != nil {
 		exitGracefully(err)
@@ -116,6 +130,6 @@ func doAuth() error {
 	color.Yellow("  - auth middleware created")
 	color.Yellow("")
 	color.Yellow("Please add user and token models in data/models.go")
-	color.Yellow("Add the appropriate middleware to your routes")
+	color.Yellow("Add the appropriate middleware to your routes, and ThrottleAuth to the login and password reset routes")
 	return nil
 }
@@ -8,21 +8,26 @@ import (
 	"strings"
 
 	"github.com/fatih/color"
-	"github.com/joho/godotenv"
+	"github.com/s-petr/celeritas/settings"
 )
 
 func setup(arg1, arg2 string) {
-	if arg1 != "new" && arg1 != "version" && arg1 != "help" {
-		if err := godotenv.Load(); err != nil {
-			exitGracefully(err)
-		}
-	}
-
 	path, err := os.Getwd()
 	if err != nil {
 		exitGracefully(err)
 	}
 
+	if arg1 != "new" && arg1 != "version" && arg1 != "help" {
+		// commands such as make do not need a valid configuration, only
+		// the settings they use
+		var invalid *settings.ValidationError
+		cfg, err := settings.Load(path)
+		if err != nil && !errors.As(err, &invalid) {
+			exitGracefully(err)
+		}
+		cel.Settings = cfg
+	}
+
 	cel.RootPath = path
 	cel.DB.DataType = os.Getenv("DATABASE_TYPE")
 }
@@ -54,9 +59,28 @@ func getDSN() string {
 		}
 		return dsn
 	}
+
+	if dbType == "sqlite" || dbType == "sqlite3" {
+		return "sqlite3://" + cel.BuildDSN()
+	}
 	return "mysql://" + cel.BuildDSN()
 }
 
+// templateDBType returns the name the migration templates use for the
+// DATABASE_TYPE in .env
+func templateDBType() string {
+	switch cel.DB.DataType {
+	case "mariadb":
+		return "mysql"
+	case "postgresql", "pgx":
+		return "postgres"
+	case "sqlite3":
+		return "sqlite"
+	default:
+		return cel.DB.DataType
+	}
+}
+
 func checkForDB() {
 	dbType := cel.DB.DataType
 
@@ -121,5 +145,11 @@ make handler <name>             - creates a stub handler in the handlers directo
 make model <name>               - creates a new model in the data directory
 make session                    - creates a table in the database as a session store
 make mail                       - creates two starter mail templates in hte mail directory
+storage sync <from> <to> <prefix> - copies changed files under prefix between file systems (e.g. sftp s3);
+                                  add --dry-run to only list changes, --delete to remove extra files,
+                                  --concurrency=N to copy N files at once
+storage disks                   - lists the configured file systems
+jobs                            - lists the scheduled jobs of the running server and their last runs
+jobs run <name>                 - runs a scheduled job on the running server straight away
 	`)
 }
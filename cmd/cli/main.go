@@ -50,6 +50,17 @@ func main() {
 			exitGracefully(err)
 		}
 		message = "Migrations complete!"
+	case "storage":
+		if arg2 == "" {
+			exitGracefully(errors.New("storage requires a subcommand (sync)"))
+		}
+		if err := doStorage(arg2, arg3, arg4); err != nil {
+			exitGracefully(err)
+		}
+	case "jobs":
+		if err := doJobs(arg2, arg3); err != nil {
+			exitGracefully(err)
+		}
 	case "exit":
 		exitGracefully(nil)
 	default:
@@ -4,16 +4,23 @@ import (
 	"fmt"
 	"net/rpc"
 	"os"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/s-petr/celeritas"
 )
 
-func rpcClient(inMaintenanceMode bool) {
+func rpcDial() *rpc.Client {
 	rpcPort := os.Getenv("RPC_PORT")
 	c, err := rpc.Dial("tcp", "127.0.0.1:"+rpcPort)
 	if err != nil {
 		exitGracefully(err)
 	}
+	return c
+}
+
+func rpcClient(inMaintenanceMode bool) {
+	c := rpcDial()
 
 	fmt.Println("Conneted...")
 	var result string
@@ -24,3 +31,48 @@ func rpcClient(inMaintenanceMode bool) {
 
 	color.Yellow(result)
 }
+
+func doJobs(arg2, arg3 string) error {
+	c := rpcDial()
+	defer c.Close()
+
+	switch arg2 {
+	case "", "list":
+		var jobs []celeritas.JobStatus
+		if err := c.Call("RPCServer.Jobs", true, &jobs); err != nil {
+			return err
+		}
+
+		if len(jobs) == 0 {
+			color.Yellow("No jobs registered")
+		}
+
+		for _, job := range jobs {
+			line := fmt.Sprintf("  %-24s %-16s next %s", job.Name, job.Spec, job.Next.Format(time.DateTime))
+			if job.Running {
+				line += " (running)"
+			}
+
+			switch {
+			case job.LastRun == nil:
+				color.White(line)
+			case job.LastRun.Error != "":
+				color.Red("%s, last run %s failed after %s: %s", line,
+					job.LastRun.Started.Format(time.DateTime), job.LastRun.Duration.Round(time.Millisecond), job.LastRun.Error)
+			default:
+				color.Green("%s, last run %s took %s", line,
+					job.LastRun.Started.Format(time.DateTime), job.LastRun.Duration.Round(time.Millisecond))
+			}
+		}
+	case "run":
+		var result string
+		if err := c.Call("RPCServer.RunJob", arg3, &result); err != nil {
+			return err
+		}
+		color.Yellow(result)
+	default:
+		showHelp()
+	}
+
+	return nil
+}
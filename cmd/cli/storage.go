@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/s-petr/celeritas/filesystems"
+)
+
+func doStorage(arg2, arg3, arg4 string) error {
+	switch arg2 {
+	case "sync":
+		if arg3 == "" || arg4 == "" {
+			return errors.New("usage: storage sync <from> <to> <prefix> [--dry-run] [--delete] [--concurrency=N]")
+		}
+
+		var prefix string
+		var opts filesystems.SyncOptions
+
+		for _, arg := range os.Args[5:] {
+			switch arg {
+			case "--dry-run":
+				opts.DryRun = true
+			case "--delete":
+				opts.Delete = true
+			default:
+				if n, ok := strings.CutPrefix(arg, "--concurrency="); ok {
+					concurrency, err := strconv.Atoi(n)
+					if err != nil {
+						return fmt.Errorf("invalid concurrency %q", n)
+					}
+					opts.Concurrency = concurrency
+					continue
+				}
+				prefix = arg
+			}
+		}
+
+		// copies are reported as they finish, since several run at once
+		opts.Progress = func(p filesystems.Progress) {
+			if !p.Done {
+				return
+			}
+			if p.Err != nil {
+				color.Red("  failed %s: %v", p.Item, p.Err)
+				return
+			}
+			color.Green("  copied %s (%d bytes)", p.Item, p.Bytes)
+		}
+
+		fileSystems, err := cel.CreateFileSystems()
+		if err != nil {
+			return err
+		}
+		cel.FileSystems = fileSystems
+
+		from, err := cel.FileSystem(arg3)
+		if err != nil {
+			return err
+		}
+
+		to, err := cel.FileSystem(arg4)
+		if err != nil {
+			return err
+		}
+
+		actions, err := filesystems.Sync(from, to, prefix, opts)
+		for _, action := range actions {
+			if action.Delete {
+				color.Red("  delete %s (%s)", action.Key, action.Reason)
+			} else if opts.DryRun {
+				color.Green("  copy   %s (%s)", action.Key, action.Reason)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if opts.DryRun {
+			color.Yellow("Dry run: %d item(s) would be changed", len(actions))
+		} else {
+			color.Yellow("%d item(s) synced", len(actions))
+		}
+	case "disks":
+		fileSystems, err := cel.CreateFileSystems()
+		if err != nil {
+			return err
+		}
+
+		for _, name := range fileSystems.Names() {
+			if name == fileSystems.DefaultName() {
+				color.Green("  %s (default)", name)
+			} else {
+				color.White("  %s", name)
+			}
+		}
+	default:
+		showHelp()
+	}
+
+	return nil
+}
@@ -0,0 +1 @@
+-- DROP TABLE some_table;
\ No newline at end of file
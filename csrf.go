@@ -0,0 +1,110 @@
+package celeritas
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/justinas/nosurf"
+	"github.com/s-petr/celeritas/settings"
+)
+
+// CSRFOptions configure the NoSurf middleware. They are read from the CSRF_*
+// settings.
+type CSRFOptions struct {
+	// Exempt lists globs of paths which are not checked, /api/* by default
+	Exempt []string
+	// SameSite is the SameSite attribute of the CSRF cookie, strict by
+	// default
+	SameSite http.SameSite
+	// HeaderMode also sends the token in a cookie readable by JavaScript,
+	// so that single page apps can send it back in a header
+	HeaderMode bool
+	// FailureView is the view rendered for forms which fail the check; if
+	// empty a plain error is sent
+	FailureView string
+}
+
+// CSRFCookieName is the cookie holding the token in header mode
+const CSRFCookieName = "XSRF-TOKEN"
+
+// csrfHeaderAlias is the header some client libraries send the token in;
+// it is accepted as well as nosurf's X-CSRF-Token
+const csrfHeaderAlias = "X-XSRF-Token"
+
+func csrfFromSettings(cfg settings.CSRF) CSRFOptions {
+	return CSRFOptions{
+		Exempt:      cfg.Exempt,
+		SameSite:    parseSameSite(cfg.CookieSameSite),
+		HeaderMode:  cfg.Mode == "header",
+		FailureView: cfg.FailureView,
+	}
+}
+
+func parseSameSite(s string) http.SameSite {
+	switch strings.ToLower(s) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
+
+// csrfHeaderMode hands the token to JavaScript in a readable cookie, and
+// accepts it back in the X-XSRF-Token header
+func (c *Celeritas) csrfHeaderMode(next http.Handler) http.Handler {
+	secure := strings.ToLower(c.config.cookie.secure) == "true"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     CSRFCookieName,
+			Value:    nosurf.Token(r),
+			Path:     "/",
+			Domain:   c.config.cookie.domain,
+			Secure:   secure,
+			SameSite: c.config.csrf.SameSite,
+		})
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrfFailed answers a request which failed the CSRF check: with JSON if
+// the client expects it, with CSRFFailureHandler if one is set, or with the
+// failure view
+func (c *Celeritas) csrfFailed(w http.ResponseWriter, r *http.Request) {
+	if c.CSRFFailureHandler != nil {
+		c.CSRFFailureHandler.ServeHTTP(w, r)
+		return
+	}
+
+	if wantsJSON(r) {
+		payload := struct {
+			Error   bool   `json:"error"`
+			Message string `json:"message"`
+		}{
+			Error:   true,
+			Message: "invalid CSRF token",
+		}
+
+		_ = c.WriteJSON(w, http.StatusBadRequest, payload)
+		return
+	}
+
+	if c.config.csrf.FailureView != "" && c.Render != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := c.Render.Page(w, r, c.config.csrf.FailureView, nil, nil); err != nil {
+			c.ErrorLog.Println(err)
+		}
+		return
+	}
+
+	http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+}
+
+// wantsJSON reports whether r was made by a script expecting a JSON reply
+func wantsJSON(r *http.Request) bool {
+	return r.Header.Get("X-Requested-With") == "XMLHttpRequest" ||
+		strings.Contains(r.Header.Get("Accept"), "application/json") ||
+		strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
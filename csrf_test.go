@@ -0,0 +1,96 @@
+package celeritas
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/s-petr/celeritas/render"
+)
+
+func newCSRFHandler(c *Celeritas) http.Handler {
+	return c.NoSurf(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+}
+
+func TestNoSurf_Failure(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "views"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "views", "csrf.page.tmpl"), []byte("<p>expired form</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCeleritas()
+	c.Render = &render.Render{Renderer: "go", RootPath: root}
+	c.config.csrf = CSRFOptions{FailureView: "csrf"}
+	handler := newCSRFHandler(c)
+
+	r := httptest.NewRequest(http.MethodPost, "/save", strings.NewReader(`{"name":"x"}`))
+	r.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"invalid CSRF token"`) {
+		t.Errorf("expected a JSON error for a script, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/save", strings.NewReader("name=x"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusBadRequest || rr.Body.String() != "<p>expired form</p>" {
+		t.Errorf("expected the failure view for a form, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestNoSurf_HeaderMode(t *testing.T) {
+	c := newTestCeleritas()
+	c.config.csrf = CSRFOptions{HeaderMode: true, Exempt: []string{"/api/*"}}
+	handler := newCSRFHandler(c)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var token string
+	var cookies []string
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == CSRFCookieName {
+			token = cookie.Value
+		}
+		cookies = append(cookies, cookie.Name+"="+cookie.Value)
+	}
+	if token == "" {
+		t.Fatalf("expected the token in the %s cookie, got %v", CSRFCookieName, cookies)
+	}
+
+	post := func(path, header string) int {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.Header.Set("Cookie", strings.Join(cookies, "; "))
+		if header != "" {
+			r.Header.Set(header, token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr.Code
+	}
+
+	if code := post("/save", csrfHeaderAlias); code != http.StatusOK {
+		t.Errorf("expected the token in %s to be accepted, got %d", csrfHeaderAlias, code)
+	}
+	if code := post("/save", "X-CSRF-Token"); code != http.StatusOK {
+		t.Errorf("expected the token in X-CSRF-Token to be accepted, got %d", code)
+	}
+	if code := post("/save", ""); code != http.StatusBadRequest {
+		t.Errorf("expected a request without the token to fail, got %d", code)
+	}
+	if code := post("/api/save", ""); code != http.StatusOK {
+		t.Errorf("expected an exempt path not to be checked, got %d", code)
+	}
+}
@@ -0,0 +1,250 @@
+package celeritas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout bounds the ping DatabaseHealth makes when the last
+// health check is missing or stale
+const healthCheckTimeout = 2 * time.Second
+
+// healthResult is the outcome of a health check made at checked
+type healthResult struct {
+	checked    time.Time
+	primaryErr error
+	replicaErr error
+}
+
+// dbHealth holds the result of the last health check, shared by the copies
+// of a Database
+type dbHealth struct {
+	mu sync.RWMutex
+	healthResult
+}
+
+// DatabaseStats describe the connection pools of a Database, for
+// monitoring
+type DatabaseStats struct {
+	Primary sql.DBStats
+	Replica *sql.DBStats `json:",omitempty"`
+	// Healthy and ReplicaHealthy are the results of the last health check,
+	// made at LastCheck; both are false before the first check
+	Healthy        bool
+	ReplicaHealthy bool
+	LastCheck      time.Time
+	Error          string `json:",omitempty"`
+	ReplicaError   string `json:",omitempty"`
+}
+
+// Writer returns the primary pool, which writes and transactions use
+func (d *Database) Writer() *sql.DB {
+	return d.Pool
+}
+
+// Reader returns the pool for queries which only read: the read replica,
+// unless there is none or the last health check could not reach it
+func (d *Database) Reader() *sql.DB {
+	if d.ReadPool == nil {
+		return d.Pool
+	}
+
+	if d.health != nil {
+		d.health.mu.RLock()
+		down := d.health.replicaErr != nil
+		d.health.mu.RUnlock()
+		if down {
+			return d.Pool
+		}
+	}
+	return d.ReadPool
+}
+
+// Route returns Reader for a SELECT which takes no locks, and Writer for
+// any other query
+func (d *Database) Route(query string) *sql.DB {
+	if isReadQuery(query) {
+		return d.Reader()
+	}
+	return d.Writer()
+}
+
+var (
+	whitespace = regexp.MustCompile(`\s+`)
+	// writeClause matches what makes a SELECT write or take locks: the
+	// locking clauses, INTO and the functions changing sequences or taking
+	// advisory locks
+	writeClause = regexp.MustCompile(`\bFOR (NO KEY )?UPDATE\b|\bFOR (KEY )?SHARE\b|\bLOCK IN SHARE MODE\b|\bINTO\b|\b(NEXTVAL|SETVAL|GET_LOCK|RELEASE_LOCK|PG_ADVISORY_\w+|PG_TRY_ADVISORY_\w+) ?\(`)
+)
+
+// isReadQuery reports whether query is a single SELECT which neither writes
+// nor takes locks. Anything it cannot tell, such as a query starting with a
+// comment or holding several statements, is not.
+func isReadQuery(query string) bool {
+	query = whitespace.ReplaceAllString(strings.ToUpper(query), " ")
+	query = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(query), ";"))
+
+	return strings.HasPrefix(query, "SELECT ") &&
+		!strings.Contains(query, ";") &&
+		!writeClause.MatchString(query)
+}
+
+// CheckHealth pings the primary and the read replica, and records the
+// result for Reader and Stats
+func (d *Database) CheckHealth(ctx context.Context) error {
+	if d.Pool == nil {
+		return errors.New("no database")
+	}
+
+	result := d.check(ctx)
+	d.record(result)
+
+	var errs []error
+	if result.primaryErr != nil {
+		errs = append(errs, fmt.Errorf("primary: %w", result.primaryErr))
+	}
+	if result.replicaErr != nil {
+		errs = append(errs, fmt.Errorf("read replica: %w", result.replicaErr))
+	}
+	return errors.Join(errs...)
+}
+
+// check pings the primary and the read replica
+func (d *Database) check(ctx context.Context) healthResult {
+	result := healthResult{checked: time.Now()}
+
+	result.primaryErr = d.Pool.PingContext(ctx)
+	if d.ReadPool != nil {
+		result.replicaErr = d.ReadPool.PingContext(ctx)
+	}
+	return result
+}
+
+// record keeps result as the last health check
+func (d *Database) record(result healthResult) {
+	if d.health == nil {
+		return
+	}
+
+	d.health.mu.Lock()
+	d.health.healthResult = result
+	d.health.mu.Unlock()
+}
+
+// lastCheck returns the last health check, which is zero if there was none
+func (d *Database) lastCheck() healthResult {
+	if d.health == nil {
+		return healthResult{}
+	}
+
+	d.health.mu.RLock()
+	defer d.health.mu.RUnlock()
+	return d.health.healthResult
+}
+
+// Stats returns the statistics of the pools and the last health check
+func (d *Database) Stats() DatabaseStats {
+	return d.stats(d.lastCheck())
+}
+
+// stats returns the statistics of the pools and the health check result
+func (d *Database) stats(result healthResult) DatabaseStats {
+	stats := DatabaseStats{LastCheck: result.checked}
+
+	if d.Pool != nil {
+		stats.Primary = d.Pool.Stats()
+	}
+	if d.ReadPool != nil {
+		replica := d.ReadPool.Stats()
+		stats.Replica = &replica
+	}
+
+	if result.checked.IsZero() {
+		return stats
+	}
+
+	stats.Healthy = result.primaryErr == nil
+	if err := result.primaryErr; err != nil {
+		stats.Error = err.Error()
+	}
+	stats.ReplicaHealthy = d.ReadPool != nil && result.replicaErr == nil
+	if err := result.replicaErr; err != nil {
+		stats.ReplicaError = err.Error()
+	}
+
+	return stats
+}
+
+// DatabaseHealth is a handler reporting the database's Stats as JSON, with
+// status 503 if there is no database or the primary is unhealthy, for load
+// balancers and monitoring to poll. The database is pinged first if it has
+// not been checked within twice DATABASE_HEALTH_INTERVAL.
+func (c *Celeritas) DatabaseHealth(w http.ResponseWriter, r *http.Request) {
+	if c.DB.Pool == nil {
+		_ = c.WriteJSON(w, http.StatusServiceUnavailable, DatabaseStats{Error: "no database"})
+		return
+	}
+
+	result := c.DB.lastCheck()
+	if c.healthStale(result.checked) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		result = c.DB.check(ctx)
+		cancel()
+		c.DB.record(result)
+	}
+
+	stats := c.DB.stats(result)
+
+	status := http.StatusOK
+	if !stats.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+
+	if err := c.WriteJSON(w, status, stats); err != nil {
+		c.ErrorLog.Println(err)
+	}
+}
+
+// healthStale reports whether a health check made at checked is too old to
+// report, which it always is when the scheduled checks are off
+func (c *Celeritas) healthStale(checked time.Time) bool {
+	var interval time.Duration
+	if c.Settings != nil {
+		interval = c.Settings.Database.HealthInterval
+	}
+
+	return checked.IsZero() || interval <= 0 || time.Since(checked) > 2*interval
+}
+
+// configurePool applies the DATABASE_ pool limits to db
+func (c *Celeritas) configurePool(db *sql.DB) {
+	if c.Settings == nil {
+		return
+	}
+
+	cfg := c.Settings.Database
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// scheduleHealthChecks checks the database every DATABASE_HEALTH_INTERVAL
+func (c *Celeritas) scheduleHealthChecks() error {
+	interval := c.Settings.Database.HealthInterval
+	if c.DB.Pool == nil || interval <= 0 {
+		return nil
+	}
+
+	return c.Scheduler.Register("database-health", "@every "+interval.String(),
+		func(ctx context.Context) error {
+			return c.DB.CheckHealth(ctx)
+		}, JobOptions{Timeout: interval})
+}
@@ -0,0 +1,113 @@
+package celeritas
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsReadQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		read  bool
+	}{
+		{"SELECT * FROM users", true},
+		{"  select id\n\tfrom users where id = $1;", true},
+		{"SELECT * FROM users FOR UPDATE", false},
+		{"SELECT * FROM users\nFOR\tUPDATE", false},
+		{"SELECT * FROM users FOR NO KEY UPDATE", false},
+		{"SELECT * FROM users FOR SHARE", false},
+		{"SELECT * FROM users FOR KEY SHARE", false},
+		{"SELECT * FROM users LOCK IN SHARE MODE", false},
+		{"SELECT * INTO archive FROM users", false},
+		{"SELECT nextval('users_id_seq')", false},
+		{"SELECT pg_advisory_lock(1)", false},
+		{"SELECT 1; DELETE FROM users", false},
+		{"/* report */ SELECT * FROM users", false},
+		{"WITH gone AS (DELETE FROM users RETURNING *) SELECT * FROM gone", false},
+		{"UPDATE users SET name = 'x'", false},
+		{"SELECTED", false},
+	}
+
+	for _, tt := range tests {
+		if got := isReadQuery(tt.query); got != tt.read {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.read, got)
+		}
+	}
+}
+
+// newTestDatabase opens a primary and a read replica in SQLite files
+func newTestDatabase(t *testing.T, c *Celeritas) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, pool := range map[string]**sql.DB{"primary": &c.DB.Pool, "replica": &c.DB.ReadPool} {
+		db, err := c.OpenDB("sqlite", "file:"+filepath.Join(dir, name+".db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		*pool = db
+	}
+	c.DB.health = &dbHealth{}
+}
+
+func TestDatabase_Route(t *testing.T) {
+	c := newTestCeleritas()
+	newTestDatabase(t, c)
+
+	if c.DB.Route("SELECT * FROM users") != c.DB.ReadPool {
+		t.Error("expected a SELECT to be routed to the replica")
+	}
+	if c.DB.Route("SELECT * FROM users FOR UPDATE") != c.DB.Pool {
+		t.Error("expected a locking SELECT to be routed to the primary")
+	}
+	if c.DB.Route("DELETE FROM users") != c.DB.Pool {
+		t.Error("expected a DELETE to be routed to the primary")
+	}
+
+	c.DB.record(healthResult{checked: time.Now(), replicaErr: errors.New("down")})
+	if c.DB.Reader() != c.DB.Pool {
+		t.Error("expected reads to fall back to the primary while the replica is down")
+	}
+}
+
+func TestDatabaseHealth(t *testing.T) {
+	c := newTestCeleritas()
+
+	health := func() (int, DatabaseStats) {
+		rr := httptest.NewRecorder()
+		c.DatabaseHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+		var stats DatabaseStats
+		if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+			t.Fatal(err)
+		}
+		return rr.Code, stats
+	}
+
+	if code, _ := health(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without a database, got %d", code)
+	}
+
+	newTestDatabase(t, c)
+
+	if stats := c.DB.Stats(); stats.Healthy {
+		t.Error("expected a database never checked not to be reported healthy")
+	}
+
+	code, stats := health()
+	if code != http.StatusOK || !stats.Healthy || !stats.ReplicaHealthy || stats.LastCheck.IsZero() {
+		t.Errorf("expected the database to be checked and healthy, got %d %+v", code, stats)
+	}
+
+	_ = c.DB.Pool.Close()
+	if code, stats := health(); code != http.StatusServiceUnavailable || stats.Error == "" {
+		t.Errorf("expected 503 for a closed primary, got %d %+v", code, stats)
+	}
+}
@@ -0,0 +1,103 @@
+package celeritas
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/s-petr/celeritas/filesystems"
+	"github.com/s-petr/celeritas/filesystems/local"
+	"github.com/s-petr/celeritas/filesystems/minio"
+	"github.com/s-petr/celeritas/filesystems/s3"
+	"github.com/s-petr/celeritas/filesystems/sftp"
+	"github.com/s-petr/celeritas/filesystems/webdav"
+)
+
+// envPrefix reads .env variables sharing a prefix, such as S3_ or
+// FS_AVATARS_
+type envPrefix string
+
+func (p envPrefix) get(name string) string {
+	return os.Getenv(string(p) + name)
+}
+
+func (p envPrefix) bool(name string) bool {
+	return strings.ToLower(p.get(name)) == "true"
+}
+
+// diskFromEnv creates a file system using driver, configured from the .env
+// variables starting with prefix
+func (c *Celeritas) diskFromEnv(driver string, prefix envPrefix) (filesystems.FS, error) {
+	switch strings.ToLower(driver) {
+	case "s3":
+		fs := s3FromEnv(prefix)
+		return &fs, nil
+	case "minio":
+		fs := minioFromEnv(prefix)
+		return &fs, nil
+	case "sftp":
+		fs := sftpFromEnv(prefix)
+		return &fs, nil
+	case "webdav":
+		fs := webDAVFromEnv(prefix)
+		return &fs, nil
+	case "local":
+		fs := c.localFromEnv(prefix)
+		return &fs, nil
+	}
+	return nil, fmt.Errorf("unknown file system driver %q", driver)
+}
+
+func s3FromEnv(env envPrefix) s3.S3 {
+	return s3.S3{
+		Key:      env.get("KEY"),
+		Secret:   env.get("SECRET"),
+		Region:   env.get("REGION"),
+		Endpoint: env.get("ENDPOINT"),
+		Bucket:   env.get("BUCKET"),
+	}
+}
+
+func minioFromEnv(env envPrefix) minio.Minio {
+	return minio.Minio{
+		Endpoint: env.get("ENDPOINT"),
+		Key:      env.get("KEY"),
+		Secret:   env.get("SECRET"),
+		UseSSL:   env.bool("USESSL"),
+		Region:   env.get("REGION"),
+		Bucket:   env.get("BUCKET"),
+	}
+}
+
+func sftpFromEnv(env envPrefix) sftp.SFTP {
+	return sftp.SFTP{
+		Host:                env.get("HOST"),
+		User:                env.get("USER"),
+		Pass:                env.get("PASS"),
+		Port:                env.get("PORT"),
+		KeyFile:             env.get("KEY_FILE"),
+		KeyPassphrase:       env.get("KEY_PASSPHRASE"),
+		UseAgent:            env.bool("USE_AGENT"),
+		KnownHostsFile:      env.get("KNOWN_HOSTS"),
+		InsecureSkipHostKey: env.bool("INSECURE_SKIP_HOST_KEY"),
+		BaseDir:             env.get("BASE_DIR"),
+	}
+}
+
+func webDAVFromEnv(env envPrefix) webdav.WebDAV {
+	return webdav.WebDAV{
+		Host: env.get("HOST"),
+		User: env.get("USER"),
+		Pass: env.get("PASS"),
+	}
+}
+
+// localFromEnv resolves a relative root against the application root
+func (c *Celeritas) localFromEnv(env envPrefix) local.Local {
+	root := env.get("ROOT")
+	if root != "" && !filepath.IsAbs(root) {
+		root = filepath.Join(c.RootPath, root)
+	}
+	return local.Local{Root: root}
+}
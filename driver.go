@@ -5,6 +5,7 @@ import (
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func (c *Celeritas) OpenDB(dbType, dsn string) (*sql.DB, error) {
@@ -12,6 +13,8 @@ func (c *Celeritas) OpenDB(dbType, dsn string) (*sql.DB, error) {
 		dbType = "pgx"
 	} else if dbType == "mysql" || dbType == "mariadb" {
 		dbType = "mysql"
+	} else if dbType == "sqlite" || dbType == "sqlite3" {
+		dbType = "sqlite3"
 	}
 
 	db, err := sql.Open(dbType, dsn)
@@ -19,8 +22,11 @@ func (c *Celeritas) OpenDB(dbType, dsn string) (*sql.DB, error) {
 		return nil, err
 	}
 
+	c.configurePool(db)
+
 	err = db.Ping()
 	if err != nil {
+		_ = db.Close()
 		return nil, err
 	}
 
@@ -1,13 +1,40 @@
 package filesystems
 
-import "time"
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotExist is returned when an item does not exist on a file system
+var ErrNotExist = errors.New("file does not exist")
 
 // FS is the interface for filesystems
 type FS interface {
 	Put(fileName, folder string) error
 	Get(destination string, items ...string) error
 	List(prefix string) ([]Listing, error)
-	Delete(itemsToDelete []string) bool
+	Delete(itemsToDelete []string) error
+	Open(item string) (io.ReadCloser, error)
+	Create(item string) (io.WriteCloser, error)
+	Stat(item string) (Listing, error)
+	Exists(item string) (bool, error)
+	Copy(source, destination string) error
+	Move(source, destination string) error
+}
+
+// Presigner is implemented by file systems that can generate time limited
+// URLs which allow clients to read or write an item directly
+type Presigner interface {
+	PresignGet(item string, expires time.Duration) (string, error)
+	PresignPut(item string, expires time.Duration) (string, error)
+}
+
+// DirLister is implemented by file systems whose List returns the entries
+// of the directory prefix by name, rather than the full key of every item
+// under prefix
+type DirLister interface {
+	ListsDirectory()
 }
 
 // Listing describes one file on a remote file system
@@ -18,3 +45,73 @@ type Listing struct {
 	Size         float64
 	IsDir        bool
 }
+
+// SizeInMB converts a size in bytes to the megabytes used by Listing
+func SizeInMB(size int64) float64 {
+	b := float64(size)
+	kb := b / 1024
+	mb := kb / 1024
+	return mb
+}
+
+// Aborter is implemented by the writers Create returns. Abort discards
+// what was written, leaving the item as it was, where Close would commit a
+// possibly truncated item.
+type Aborter interface {
+	Abort(err error) error
+}
+
+// ErrAborted is passed to an upload aborted without a reason
+var ErrAborted = errors.New("write aborted")
+
+// Abort aborts w if it is an Aborter, and closes it otherwise. Callers
+// abort a writer from Create on every error path, instead of closing it.
+func Abort(w io.WriteCloser, err error) error {
+	if a, ok := w.(Aborter); ok {
+		return a.Abort(err)
+	}
+	return w.Close()
+}
+
+type pipeWriter struct {
+	*io.PipeWriter
+	done chan error
+}
+
+// NewPipeWriter returns a WriteCloser that streams everything written to it
+// into upload, which runs in its own goroutine. Close waits for upload to
+// finish and returns its error. The writer is an Aborter: Abort makes the
+// reader upload is given fail, so that the upload is abandoned.
+func NewPipeWriter(upload func(r io.Reader) error) io.WriteCloser {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		err := upload(pr)
+		// unblock any pending writes if the upload stopped reading early
+		_ = pr.CloseWithError(err)
+		done <- err
+	}()
+
+	return &pipeWriter{PipeWriter: pw, done: done}
+}
+
+func (p *pipeWriter) Close() error {
+	if err := p.PipeWriter.Close(); err != nil {
+		return err
+	}
+	return <-p.done
+}
+
+func (p *pipeWriter) Abort(err error) error {
+	if err == nil {
+		err = ErrAborted
+	}
+	_ = p.PipeWriter.CloseWithError(err)
+
+	// the upload fails with err, which the caller already has
+	if uploadErr := <-p.done; uploadErr != nil && !errors.Is(uploadErr, err) {
+		return uploadErr
+	}
+	return nil
+}
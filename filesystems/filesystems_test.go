@@ -0,0 +1,66 @@
+package filesystems
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPipeWriter_Abort(t *testing.T) {
+	var committed bool
+
+	w := NewPipeWriter(func(r io.Reader) error {
+		if _, err := io.ReadAll(r); err != nil {
+			return err
+		}
+		committed = true
+		return nil
+	})
+
+	if _, err := io.WriteString(w, "partial"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Abort(w, errors.New("client went away")); err != nil {
+		t.Error("expected no error aborting, got", err)
+	}
+	if committed {
+		t.Error("expected an aborted upload not to be committed")
+	}
+}
+
+func TestPathError_Error(t *testing.T) {
+	err := &PathError{Op: "get", Key: "a.txt"}
+	if got := err.Error(); got != "get a.txt: failed" {
+		t.Errorf("expected \"get a.txt: failed\", got %q", got)
+	}
+
+	if got := (&PathError{}).Error(); got == "" {
+		t.Error("expected a zero PathError to have a message")
+	}
+}
+
+func TestChanged(t *testing.T) {
+	modified := time.Now()
+	src := Listing{Key: "a.txt", Size: 1, LastModified: modified, Etag: `"abc"`}
+
+	tests := []struct {
+		dst          *Listing
+		compareEtags bool
+		want         string
+	}{
+		{nil, true, "missing"},
+		{&Listing{Size: 2, LastModified: modified}, true, "size"},
+		{&Listing{Size: 1, LastModified: modified, Etag: "abc"}, true, ""},
+		{&Listing{Size: 1, LastModified: modified, Etag: `"def"`}, true, "etag"},
+		{&Listing{Size: 1, LastModified: modified, Etag: "def"}, false, ""},
+		{&Listing{Size: 1, LastModified: modified.Add(-time.Hour), Etag: "def"}, false, "modified"},
+	}
+
+	for i, tt := range tests {
+		if got := changed(src, tt.dst, tt.compareEtags); got != tt.want {
+			t.Errorf("%d: expected %q, got %q", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/s-petr/celeritas/filesystems"
+)
+
+// Local stores files on the local disk. All items are resolved relative to
+// Root and cannot escape it; if Root is empty, paths are used as given.
+// Get and Delete handle up to Concurrency items at once, reporting to
+// Progress if it is set.
+type Local struct {
+	Root        string
+	Concurrency int
+	Progress    filesystems.ProgressFunc
+}
+
+func (l *Local) path(item string) string {
+	if l.Root == "" {
+		if item == "" {
+			return "."
+		}
+		return filepath.FromSlash(item)
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(path.Clean("/"+item)))
+}
+
+func (l *Local) Put(fileName, folder string) error {
+	src, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := l.Create(path.Join(filepath.ToSlash(folder), filepath.Base(fileName)))
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = filesystems.Abort(dst, err)
+		return err
+	}
+
+	return dst.Close()
+}
+
+func (l *Local) List(prefix string) ([]filesystems.Listing, error) {
+	var listing []filesystems.Listing
+
+	dir := prefix
+	if info, err := os.Stat(l.path(dir)); err != nil || !info.IsDir() {
+		dir = path.Dir(prefix)
+	}
+
+	root := l.path("")
+	err := filepath.WalkDir(l.path(dir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if strings.HasPrefix(d.Name(), ".") && p != l.path(dir) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, strings.TrimPrefix(prefix, "/")) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		listing = append(listing, filesystems.Listing{
+			LastModified: info.ModTime(),
+			Key:          key,
+			Size:         filesystems.SizeInMB(info.Size()),
+		})
+		return nil
+	})
+
+	return listing, err
+}
+
+func (l *Local) Delete(itemsToDelete []string) error {
+	return filesystems.ForEach("delete", itemsToDelete, l.Concurrency, func(item string) error {
+		return notFound(os.Remove(l.path(item)))
+	})
+}
+
+func (l *Local) Get(destination string, items ...string) error {
+	return filesystems.ForEach("get", items, l.Concurrency, func(item string) (err error) {
+		src, err := os.Open(l.path(item))
+		if err != nil {
+			return notFound(err)
+		}
+		defer src.Close()
+
+		total := int64(-1)
+		if info, err := src.Stat(); err == nil {
+			total = info.Size()
+		}
+
+		tracker := filesystems.NewTracker(l.Progress, item, total)
+		defer func() { tracker.Done(err) }()
+
+		dst, err := os.Create(fmt.Sprintf("%s/%s", destination, path.Base(item)))
+		if err != nil {
+			return err
+		}
+		defer dst.Close()
+
+		if _, err := io.Copy(tracker.Writer(dst), src); err != nil {
+			return err
+		}
+
+		return dst.Sync()
+	})
+}
+
+func (l *Local) Open(item string) (io.ReadCloser, error) {
+	f, err := os.Open(l.path(item))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return f, nil
+}
+
+// Create writes item to a hidden temporary file, which Close renames to
+// item and Abort removes, so that item is never left half written
+func (l *Local) Create(item string) (io.WriteCloser, error) {
+	p := l.path(item)
+
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*.part")
+	if err != nil {
+		return nil, err
+	}
+
+	return &tempFile{File: f, path: p}, nil
+}
+
+type tempFile struct {
+	*os.File
+	path string
+}
+
+func (f *tempFile) Close() error {
+	if err := f.Chmod(0644); err != nil {
+		return f.Abort(err)
+	}
+	if err := f.File.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), f.path)
+}
+
+func (f *tempFile) Abort(error) error {
+	_ = f.File.Close()
+	return os.Remove(f.Name())
+}
+
+func (l *Local) Stat(item string) (filesystems.Listing, error) {
+	info, err := os.Stat(l.path(item))
+	if err != nil {
+		return filesystems.Listing{}, notFound(err)
+	}
+
+	return filesystems.Listing{
+		LastModified: info.ModTime(),
+		Key:          item,
+		Size:         filesystems.SizeInMB(info.Size()),
+		IsDir:        info.IsDir(),
+	}, nil
+}
+
+func (l *Local) Exists(item string) (bool, error) {
+	_, err := l.Stat(item)
+	if errors.Is(err, filesystems.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *Local) Copy(source, destination string) error {
+	src, err := l.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := l.Create(destination)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = filesystems.Abort(dst, err)
+		return err
+	}
+
+	return dst.Close()
+}
+
+func (l *Local) Move(source, destination string) error {
+	dst := l.path(destination)
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	return notFound(os.Rename(l.path(source), dst))
+}
+
+// notFound translates missing file errors into filesystems.ErrNotExist
+func notFound(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", filesystems.ErrNotExist, err.Error())
+	}
+	return err
+}
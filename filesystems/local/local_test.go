@@ -0,0 +1,240 @@
+package local
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/s-petr/celeritas/filesystems"
+)
+
+func writeTestFile(t *testing.T, item, content string) {
+	w, err := testLocal.Create(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(w, content); err != nil {
+		t.Error(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func readTestFile(t *testing.T, item string) string {
+	r, err := testLocal.Open(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Error(err)
+	}
+	return string(data)
+}
+
+func TestLocal_CreateOpen(t *testing.T) {
+	writeTestFile(t, "docs/hello.txt", "hello world")
+
+	if content := readTestFile(t, "docs/hello.txt"); content != "hello world" {
+		t.Errorf("expected \"hello world\", got %q", content)
+	}
+
+	if _, err := testLocal.Open("docs/missing.txt"); !errors.Is(err, filesystems.ErrNotExist) {
+		t.Errorf("expected ErrNotExist opening missing file, got %v", err)
+	}
+}
+
+func TestLocal_CreateAbort(t *testing.T) {
+	writeTestFile(t, "docs/abort.txt", "original")
+
+	w, err := testLocal.Create("docs/abort.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(w, "trunc"); err != nil {
+		t.Error(err)
+	}
+
+	if err := filesystems.Abort(w, errors.New("client went away")); err != nil {
+		t.Error(err)
+	}
+
+	if content := readTestFile(t, "docs/abort.txt"); content != "original" {
+		t.Errorf("expected an aborted write to leave \"original\", got %q", content)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(testLocal.Root, "docs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".part" {
+			t.Error("expected the temporary file to be removed, found", entry.Name())
+		}
+	}
+}
+
+func TestLocal_StaysInRoot(t *testing.T) {
+	writeTestFile(t, "../../escape.txt", "trapped")
+
+	if _, err := os.Stat(filepath.Join(testLocal.Root, "escape.txt")); err != nil {
+		t.Error("expected file to be created inside the root directory", err)
+	}
+}
+
+func TestLocal_PutGet(t *testing.T) {
+	tmp := t.TempDir()
+	fileName := filepath.Join(tmp, "put.txt")
+
+	if err := os.WriteFile(fileName, []byte("put"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testLocal.Put(fileName, "uploads"); err != nil {
+		t.Error(err)
+	}
+
+	exists, err := testLocal.Exists("uploads/put.txt")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !exists {
+		t.Error("uploads/put.txt not found but it should be there")
+	}
+
+	download := filepath.Join(tmp, "download")
+	if err := os.Mkdir(download, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testLocal.Get(download, "uploads/put.txt"); err != nil {
+		t.Error(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(download, "put.txt"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(data) != "put" {
+		t.Errorf("expected \"put\", got %q", string(data))
+	}
+}
+
+func TestLocal_List(t *testing.T) {
+	writeTestFile(t, "list/one.txt", "one")
+	writeTestFile(t, "list/nested/two.txt", "two")
+	writeTestFile(t, "list/.hidden", "hidden")
+
+	listing, err := testLocal.List("list/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make(map[string]bool)
+	for _, item := range listing {
+		keys[item.Key] = true
+	}
+
+	if len(keys) != 2 || !keys["list/one.txt"] || !keys["list/nested/two.txt"] {
+		t.Errorf("unexpected listing %+v", listing)
+	}
+}
+
+func TestLocal_CopyMoveDelete(t *testing.T) {
+	writeTestFile(t, "copy/source.txt", "source")
+
+	if err := testLocal.Copy("copy/source.txt", "copy/copied.txt"); err != nil {
+		t.Error(err)
+	}
+
+	if err := testLocal.Move("copy/copied.txt", "moved/moved.txt"); err != nil {
+		t.Error(err)
+	}
+
+	if content := readTestFile(t, "moved/moved.txt"); content != "source" {
+		t.Errorf("expected \"source\", got %q", content)
+	}
+
+	if exists, _ := testLocal.Exists("copy/copied.txt"); exists {
+		t.Error("copy/copied.txt found but it should have been moved")
+	}
+
+	if err := testLocal.Delete([]string{"copy/source.txt", "moved/moved.txt"}); err != nil {
+		t.Error("failed to delete files", err)
+	}
+
+	if exists, _ := testLocal.Exists("copy/source.txt"); exists {
+		t.Error("copy/source.txt found but it should have been deleted")
+	}
+}
+
+func TestLocal_DeleteErrors(t *testing.T) {
+	writeTestFile(t, "delete/one.txt", "one")
+	writeTestFile(t, "delete/two.txt", "two")
+
+	err := testLocal.Delete([]string{"delete/one.txt", "delete/missing.txt", "delete/two.txt"})
+
+	var multi *filesystems.MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+
+	if len(multi.Errors) != 1 || multi.Errors[0].Key != "delete/missing.txt" {
+		t.Errorf("expected a single error for delete/missing.txt, got %v", multi.Errors)
+	}
+
+	if !errors.Is(err, filesystems.ErrNotExist) {
+		t.Error("expected the aggregated error to wrap ErrNotExist")
+	}
+
+	for _, item := range []string{"delete/one.txt", "delete/two.txt"} {
+		if exists, _ := testLocal.Exists(item); exists {
+			t.Errorf("%s found but it should have been deleted despite the other failure", item)
+		}
+	}
+}
+
+func TestLocal_GetProgress(t *testing.T) {
+	items := []string{"progress/a.txt", "progress/b.txt", "progress/c.txt"}
+	for _, item := range items {
+		writeTestFile(t, item, "some content")
+	}
+
+	var mu sync.Mutex
+	done := make(map[string]filesystems.Progress)
+
+	l := Local{Root: testLocal.Root, Concurrency: 2, Progress: func(p filesystems.Progress) {
+		if p.Done {
+			mu.Lock()
+			done[p.Item] = p
+			mu.Unlock()
+		}
+	}}
+
+	if err := l.Get(t.TempDir(), items...); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range items {
+		p, ok := done[item]
+		if !ok {
+			t.Errorf("no final progress report for %s", item)
+			continue
+		}
+
+		if p.Bytes != 12 || p.Total != 12 || p.Err != nil {
+			t.Errorf("unexpected final progress for %s: %+v", item, p)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package local
+
+import (
+	"log"
+	"os"
+	"testing"
+)
+
+var testLocal Local
+
+func TestMain(m *testing.M) {
+	root, err := os.MkdirTemp("", "celeritas-local")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	testLocal.Root = root
+
+	code := m.Run()
+	_ = os.RemoveAll(root)
+	os.Exit(code)
+}
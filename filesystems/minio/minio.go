@@ -2,23 +2,34 @@ package minio
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/url"
+	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/s-petr/celeritas/filesystems"
 )
 
+// Minio stores files in a MinIO bucket. Objects larger than PartSize are
+// uploaded in parts, Concurrency parts at a time; Get and Delete likewise
+// handle up to Concurrency items at once, reporting to Progress if it is set.
 type Minio struct {
-	Endpoint string
-	Key      string
-	Secret   string
-	UseSSL   bool
-	Region   string
-	Bucket   string
+	Endpoint    string
+	Key         string
+	Secret      string
+	UseSSL      bool
+	Region      string
+	Bucket      string
+	PartSize    uint64
+	Concurrency int
+	Progress    filesystems.ProgressFunc
 }
 
 func (m *Minio) getCredentials() *minio.Client {
@@ -33,16 +44,44 @@ func (m *Minio) getCredentials() *minio.Client {
 	return client
 }
 
-func (m *Minio) Put(fileName, folder string) error {
+// streamPartSize is the part size used for streams of unknown size when
+// PartSize is not set. Left to itself minio-go would size the parts for the
+// largest possible object, and buffer over 500 MiB for each stream.
+const streamPartSize = 16 << 20
+
+// putOptions returns the options for uploading an object of size bytes, or
+// of unknown size if size is -1
+func (m *Minio) putOptions(tracker *filesystems.Tracker, size int64) minio.PutObjectOptions {
+	opts := minio.PutObjectOptions{PartSize: m.PartSize}
+	if opts.PartSize == 0 && size < 0 {
+		opts.PartSize = streamPartSize
+	}
+	if m.Concurrency > 0 {
+		opts.NumThreads = uint(m.Concurrency)
+	}
+	if tracker != nil && m.Progress != nil {
+		opts.Progress = tracker
+	}
+	return opts
+}
+
+func (m *Minio) Put(fileName, folder string) (err error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	objectName := path.Base(fileName)
+	objectName := fmt.Sprintf("%s/%s", folder, path.Base(fileName))
 	client := m.getCredentials()
 
-	uploadInfo, err := client.FPutObject(ctx, m.Bucket,
-		fmt.Sprintf("%s/%s", folder, objectName),
-		fileName, minio.PutObjectOptions{})
+	total := int64(-1)
+	if info, err := os.Stat(fileName); err == nil {
+		total = info.Size()
+	}
+
+	tracker := filesystems.NewTracker(m.Progress, objectName, total)
+	defer func() { tracker.Done(err) }()
+
+	uploadInfo, err := client.FPutObject(ctx, m.Bucket, objectName,
+		fileName, m.putOptions(tracker, total))
 	if err != nil {
 		log.Println("FPutObject failed")
 		log.Println(err)
@@ -69,7 +108,6 @@ func (m *Minio) List(prefix string) ([]filesystems.Listing, error) {
 
 	for object := range objectCh {
 		if object.Err != nil {
-			fmt.Sprintln(object.Err)
 			return listing, object.Err
 		}
 
@@ -89,24 +127,42 @@ func (m *Minio) List(prefix string) ([]filesystems.Listing, error) {
 	return listing, nil
 }
 
-func (m *Minio) Delete(itemsToDelete []string) bool {
+func (m *Minio) Delete(itemsToDelete []string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	client := m.getCredentials()
 
-	opts := minio.RemoveObjectOptions{
+	objectsCh := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objectsCh)
+		for _, item := range itemsToDelete {
+			select {
+			case objectsCh <- minio.ObjectInfo{Key: item}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var errs []*filesystems.PathError
+
+	opts := minio.RemoveObjectsOptions{
 		GovernanceBypass: true,
 	}
 
-	for _, item := range itemsToDelete {
-		err := client.RemoveObject(ctx, m.Bucket, item, opts)
-		if err != nil {
-			fmt.Println(err)
-			return false
-		}
+	for result := range client.RemoveObjects(ctx, m.Bucket, objectsCh, opts) {
+		errs = append(errs, &filesystems.PathError{
+			Op:  "delete",
+			Key: result.ObjectName,
+			Err: notFound(result.Err),
+		})
+	}
+
+	if len(errs) > 0 {
+		return &filesystems.MultiError{Errors: errs}
 	}
-	return true
+	return nil
 }
 
 func (m *Minio) Get(destination string, items ...string) error {
@@ -115,13 +171,139 @@ func (m *Minio) Get(destination string, items ...string) error {
 
 	client := m.getCredentials()
 
-	for _, item := range items {
-		if err := client.FGetObject(ctx, m.Bucket, item,
-			fmt.Sprintf("%s/%s", destination, path.Base(item)),
-			minio.GetObjectOptions{}); err != nil {
-			fmt.Println(err)
+	return filesystems.ForEach("get", items, m.Concurrency, func(item string) (err error) {
+		var tracker *filesystems.Tracker
+		defer func() { tracker.Done(err) }()
+
+		object, err := client.GetObject(ctx, m.Bucket, item, minio.GetObjectOptions{})
+		if err != nil {
+			return notFound(err)
+		}
+		defer object.Close()
+
+		info, err := object.Stat()
+		if err != nil {
+			return notFound(err)
+		}
+
+		tracker = filesystems.NewTracker(m.Progress, item, info.Size)
+
+		file, err := os.Create(fmt.Sprintf("%s/%s", destination, path.Base(item)))
+		if err != nil {
 			return err
 		}
+		defer file.Close()
+
+		if _, err := io.Copy(tracker.Writer(file), object); err != nil {
+			return err
+		}
+
+		return file.Sync()
+	})
+}
+
+func (m *Minio) Open(item string) (io.ReadCloser, error) {
+	client := m.getCredentials()
+
+	object, err := client.GetObject(context.Background(), m.Bucket, item,
+		minio.GetObjectOptions{})
+	if err != nil {
+		return nil, notFound(err)
 	}
-	return nil
+
+	// GetObject is lazy, so stat the object to surface missing keys now
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, notFound(err)
+	}
+
+	return object, nil
+}
+
+func (m *Minio) Create(item string) (io.WriteCloser, error) {
+	client := m.getCredentials()
+
+	return filesystems.NewPipeWriter(func(r io.Reader) error {
+		_, err := client.PutObject(context.Background(), m.Bucket, item, r, -1,
+			m.putOptions(nil, -1))
+		return err
+	}), nil
+}
+
+func (m *Minio) Stat(item string) (filesystems.Listing, error) {
+	client := m.getCredentials()
+
+	info, err := client.StatObject(context.Background(), m.Bucket, item,
+		minio.StatObjectOptions{})
+	if err != nil {
+		return filesystems.Listing{}, notFound(err)
+	}
+
+	return filesystems.Listing{
+		Etag:         info.ETag,
+		LastModified: info.LastModified,
+		Key:          info.Key,
+		Size:         filesystems.SizeInMB(info.Size),
+	}, nil
+}
+
+func (m *Minio) Exists(item string) (bool, error) {
+	_, err := m.Stat(item)
+	if errors.Is(err, filesystems.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (m *Minio) Copy(source, destination string) error {
+	client := m.getCredentials()
+
+	_, err := client.CopyObject(context.Background(),
+		minio.CopyDestOptions{Bucket: m.Bucket, Object: destination},
+		minio.CopySrcOptions{Bucket: m.Bucket, Object: source})
+	return notFound(err)
+}
+
+func (m *Minio) Move(source, destination string) error {
+	if err := m.Copy(source, destination); err != nil {
+		return err
+	}
+
+	client := m.getCredentials()
+	return client.RemoveObject(context.Background(), m.Bucket, source,
+		minio.RemoveObjectOptions{})
+}
+
+// notFound translates the MinIO "no such key" errors into filesystems.ErrNotExist
+func notFound(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return fmt.Errorf("%w: %s", filesystems.ErrNotExist, err.Error())
+	}
+	return err
+}
+
+func (m *Minio) PresignGet(item string, expires time.Duration) (string, error) {
+	client := m.getCredentials()
+
+	u, err := client.PresignedGetObject(context.Background(), m.Bucket, item,
+		expires, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (m *Minio) PresignPut(item string, expires time.Duration) (string, error) {
+	client := m.getCredentials()
+
+	u, err := client.PresignedPutObject(context.Background(), m.Bucket, item, expires)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
 }
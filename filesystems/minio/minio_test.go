@@ -0,0 +1,22 @@
+package minio
+
+import "testing"
+
+func TestMinio_PutOptions(t *testing.T) {
+	m := &Minio{Concurrency: 4}
+
+	// the options Create uploads its stream with
+	if opts := m.putOptions(nil, -1); opts.PartSize != streamPartSize || opts.NumThreads != 4 {
+		t.Errorf("expected parts of %d bytes over 4 threads for a stream, got %d over %d",
+			streamPartSize, opts.PartSize, opts.NumThreads)
+	}
+
+	if opts := m.putOptions(nil, 1024); opts.PartSize != 0 {
+		t.Errorf("expected minio to size the parts of a known size, got %d", opts.PartSize)
+	}
+
+	m.PartSize = 64 << 20
+	if opts := m.putOptions(nil, -1); opts.PartSize != m.PartSize {
+		t.Errorf("expected PartSize to be kept, got %d", opts.PartSize)
+	}
+}
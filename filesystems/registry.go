@@ -0,0 +1,108 @@
+package filesystems
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNoDefault is returned by Registry.Default when no default disk is set
+var ErrNoDefault = errors.New("no default file system configured")
+
+// Registry holds the application's file systems ("disks") by name, so that
+// several disks can use the same driver, e.g. an "avatars" disk and a
+// "backups" disk both on S3. Names are case insensitive.
+type Registry struct {
+	mu    sync.RWMutex
+	disks map[string]FS
+	def   string
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{disks: make(map[string]FS)}
+}
+
+// Register adds fs under name, replacing any disk already registered with it
+func (r *Registry) Register(name string, fs FS) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.disks[strings.ToLower(name)] = fs
+}
+
+// Disk returns the disk registered under name
+func (r *Registry) Disk(name string) (FS, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fs, ok := r.disks[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("file system %s is not configured", name)
+	}
+	return fs, nil
+}
+
+// SetDefault makes the disk registered under name the default
+func (r *Registry) SetDefault(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.disks[strings.ToLower(name)]; !ok {
+		return fmt.Errorf("file system %s is not configured", name)
+	}
+
+	r.def = strings.ToLower(name)
+	return nil
+}
+
+// Default returns the default disk, or ErrNoDefault if there is none
+func (r *Registry) Default() (FS, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.def == "" {
+		return nil, ErrNoDefault
+	}
+	return r.disks[r.def], nil
+}
+
+// DefaultName returns the name of the default disk, if any
+func (r *Registry) DefaultName() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.def
+}
+
+// Names returns the names of all registered disks in sorted order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.disks))
+	for name := range r.disks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close closes every disk which holds open connections
+func (r *Registry) Close() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var errs []error
+	for _, fs := range r.disks {
+		if closer, ok := fs.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
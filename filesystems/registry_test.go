@@ -0,0 +1,53 @@
+package filesystems_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/s-petr/celeritas/filesystems"
+	"github.com/s-petr/celeritas/filesystems/local"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := filesystems.NewRegistry()
+
+	if _, err := registry.Default(); !errors.Is(err, filesystems.ErrNoDefault) {
+		t.Errorf("expected ErrNoDefault from an empty registry, got %v", err)
+	}
+
+	avatars := &local.Local{Root: t.TempDir()}
+	backups := &local.Local{Root: t.TempDir()}
+
+	registry.Register("avatars", avatars)
+	registry.Register("Backups", backups)
+
+	fs, err := registry.Disk("AVATARS")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fs != avatars {
+		t.Error("wrong disk returned for avatars")
+	}
+
+	if _, err := registry.Disk("missing"); err == nil {
+		t.Error("expected an error for a disk that is not registered")
+	}
+
+	if err := registry.SetDefault("missing"); err == nil {
+		t.Error("expected an error setting an unknown default")
+	}
+
+	if err := registry.SetDefault("backups"); err != nil {
+		t.Fatal(err)
+	}
+
+	if fs, _ := registry.Default(); fs != backups {
+		t.Error("wrong default disk returned")
+	}
+
+	names := registry.Names()
+	if len(names) != 2 || names[0] != "avatars" || names[1] != "backups" {
+		t.Errorf("unexpected disk names %v", names)
+	}
+}
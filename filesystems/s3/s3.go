@@ -1,11 +1,14 @@
 package s3
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -16,14 +19,24 @@ import (
 	"github.com/s-petr/celeritas/filesystems"
 )
 
+// S3 stores files in an S3 compatible bucket. Objects larger than PartSize
+// are uploaded and downloaded in parts, Concurrency parts at a time; Get and
+// Delete likewise handle up to Concurrency items at once, reporting to
+// Progress if it is set.
 type S3 struct {
-	Key      string
-	Secret   string
-	Region   string
-	Endpoint string
-	Bucket   string
+	Key         string
+	Secret      string
+	Region      string
+	Endpoint    string
+	Bucket      string
+	PartSize    int64
+	Concurrency int
+	Progress    filesystems.ProgressFunc
 }
 
+// maxDeleteBatch is the most keys a single DeleteObjects request may contain
+const maxDeleteBatch = 1000
+
 func (s *S3) getSession() *session.Session {
 	c := credentials.NewStaticCredentials(s.Key, s.Secret, "")
 
@@ -38,10 +51,30 @@ func (s *S3) getSession() *session.Session {
 	return sess
 }
 
-func (s *S3) Put(fileName, folder string) error {
-	sess := s.getSession()
+func (s *S3) uploader(sess *session.Session) *s3manager.Uploader {
+	return s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
+		if s.PartSize > 0 {
+			u.PartSize = s.PartSize
+		}
+		if s.Concurrency > 0 {
+			u.Concurrency = s.Concurrency
+		}
+	})
+}
 
-	uploader := s3manager.NewUploader(sess)
+func (s *S3) downloader(sess *session.Session) *s3manager.Downloader {
+	return s3manager.NewDownloader(sess, func(d *s3manager.Downloader) {
+		if s.PartSize > 0 {
+			d.PartSize = s.PartSize
+		}
+		if s.Concurrency > 0 {
+			d.Concurrency = s.Concurrency
+		}
+	})
+}
+
+func (s *S3) Put(fileName, folder string) (err error) {
+	uploader := s.uploader(s.getSession())
 
 	f, err := os.Open(fileName)
 	if err != nil {
@@ -54,23 +87,28 @@ func (s *S3) Put(fileName, folder string) error {
 		return err
 	}
 
-	var size = fileInfo.Size()
-
-	buffer := make([]byte, size)
+	key := fmt.Sprintf("%s/%s", folder, path.Base(fileName))
+	tracker := filesystems.NewTracker(s.Progress, key, fileInfo.Size())
+	defer func() { tracker.Done(err) }()
 
-	if _, err = f.Read(buffer); err != nil {
+	// sniff the content type from the start of the file, then stream the
+	// whole file so large uploads are sent in parts
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
 		return err
 	}
 
-	fileBytes := bytes.NewReader(buffer)
-	fileType := http.DetectContentType(buffer)
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
 
 	_, err = uploader.Upload(&s3manager.UploadInput{
 		Bucket:      aws.String(s.Bucket),
-		Key:         aws.String(fmt.Sprintf("%s/%s", folder, path.Base(fileName))),
-		Body:        fileBytes,
+		Key:         aws.String(key),
+		Body:        tracker.Reader(f),
 		ACL:         aws.String("public-read"),
-		ContentType: aws.String(fileType),
+		ContentType: aws.String(http.DetectContentType(head[:n])),
 		Metadata: map[string]*string{
 			"Key": aws.String("MetadataValue"),
 		},
@@ -82,100 +120,212 @@ func (s *S3) Put(fileName, folder string) error {
 func (s *S3) List(prefix string) ([]filesystems.Listing, error) {
 	var listing []filesystems.Listing
 
-	sess := s.getSession()
-
-	svc := s3.New(sess)
-	input := &s3.ListObjectsInput{
+	svc := s3.New(s.getSession())
+	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.Bucket),
 		Prefix: aws.String(prefix),
 	}
 
-	result, err := svc.ListObjects(input)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case s3.ErrCodeNoSuchBucket:
-				fmt.Println("AWS Error:", s3.ErrCodeNoSuchBucket, aerr.Error())
-			default:
-				fmt.Println("AWS Error:", aerr.Error())
-			}
-		} else {
-			fmt.Println("Other Error:", err.Error())
+	err := svc.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, key := range page.Contents {
+			listing = append(listing, filesystems.Listing{
+				Etag:         aws.StringValue(key.ETag),
+				LastModified: aws.TimeValue(key.LastModified),
+				Key:          aws.StringValue(key.Key),
+				Size:         filesystems.SizeInMB(aws.Int64Value(key.Size)),
+			})
 		}
+		return true
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	for _, key := range result.Contents {
-		b := float64(*key.Size)
-		kb := b / 1024
-		mb := kb / 1024
-		current := filesystems.Listing{
-			Etag:         *key.ETag,
-			LastModified: *key.LastModified,
-			Key:          *key.Key,
-			Size:         mb,
-		}
-		listing = append(listing, current)
-	}
-
 	return listing, nil
 }
 
-func (s *S3) Delete(itemsToDelete []string) bool {
-	sess := s.getSession()
+func (s *S3) Delete(itemsToDelete []string) error {
+	svc := s3.New(s.getSession())
 
-	svc := s3.New(sess)
+	var errs []*filesystems.PathError
+
+	for start := 0; start < len(itemsToDelete); start += maxDeleteBatch {
+		batch := itemsToDelete[start:min(start+maxDeleteBatch, len(itemsToDelete))]
+
+		objects := make([]*s3.ObjectIdentifier, len(batch))
+		for i, item := range batch {
+			objects[i] = &s3.ObjectIdentifier{Key: aws.String(item)}
+		}
 
-	for _, item := range itemsToDelete {
-		input := &s3.DeleteObjectsInput{
+		result, err := svc.DeleteObjects(&s3.DeleteObjectsInput{
 			Bucket: &s.Bucket,
 			Delete: &s3.Delete{
-				Objects: []*s3.ObjectIdentifier{
-					{Key: aws.String(item)},
-				},
-				Quiet: aws.Bool(false),
+				Objects: objects,
+				Quiet:   aws.Bool(true),
 			},
-		}
-
-		_, err := svc.DeleteObjects(input)
+		})
 		if err != nil {
-			if aerr, ok := err.(awserr.Error); ok {
-				switch aerr.Code() {
-				case s3.ErrCodeNoSuchBucket:
-					fmt.Println("AWS Error:", s3.ErrCodeNoSuchBucket, aerr.Error())
-				default:
-					fmt.Println("AWS Error:", aerr.Error())
-				}
-			} else {
-				fmt.Println("Other Error:", err.Error())
+			for _, item := range batch {
+				errs = append(errs, &filesystems.PathError{Op: "delete", Key: item, Err: err})
 			}
-			return false
+			continue
+		}
+
+		for _, e := range result.Errors {
+			errs = append(errs, &filesystems.PathError{
+				Op:  "delete",
+				Key: aws.StringValue(e.Key),
+				Err: notFound(awserr.New(aws.StringValue(e.Code), aws.StringValue(e.Message), nil)),
+			})
 		}
 	}
-	return true
+
+	if len(errs) > 0 {
+		return &filesystems.MultiError{Errors: errs}
+	}
+	return nil
 }
 
 func (s *S3) Get(destination string, items ...string) error {
 	sess := s.getSession()
+	svc := s3.New(sess)
+	downloader := s.downloader(sess)
 
-	for _, item := range items {
-		err := func() error {
-			file, err := os.Create(fmt.Sprintf("%s/%s", destination, item))
-			if err != nil {
-				return err
+	return filesystems.ForEach("get", items, s.Concurrency, func(item string) (err error) {
+		total := int64(-1)
+		if s.Progress != nil {
+			result, err := svc.HeadObject(&s3.HeadObjectInput{
+				Bucket: aws.String(s.Bucket),
+				Key:    aws.String(item),
+			})
+			if err == nil {
+				total = aws.Int64Value(result.ContentLength)
 			}
-			defer file.Close()
-
-			downloader := s3manager.NewDownloader(sess)
-			_, err = downloader.Download(file,
-				&s3.GetObjectInput{
-					Bucket: aws.String(s.Bucket),
-					Key:    aws.String(item),
-				})
+		}
+
+		tracker := filesystems.NewTracker(s.Progress, item, total)
+		defer func() { tracker.Done(err) }()
+
+		file, err := os.Create(fmt.Sprintf("%s/%s", destination, path.Base(item)))
+		if err != nil {
 			return err
-		}()
+		}
+		defer file.Close()
+
+		_, err = downloader.Download(tracker.WriterAt(file),
+			&s3.GetObjectInput{
+				Bucket: aws.String(s.Bucket),
+				Key:    aws.String(item),
+			})
+		return notFound(err)
+	})
+}
+
+func (s *S3) Open(item string) (io.ReadCloser, error) {
+	svc := s3.New(s.getSession())
+
+	result, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(item),
+	})
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return result.Body, nil
+}
+
+func (s *S3) Create(item string) (io.WriteCloser, error) {
+	uploader := s.uploader(s.getSession())
+
+	return filesystems.NewPipeWriter(func(r io.Reader) error {
+		_, err := uploader.Upload(&s3manager.UploadInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(item),
+			Body:   r,
+		})
 		return err
+	}), nil
+}
+
+func (s *S3) Stat(item string) (filesystems.Listing, error) {
+	svc := s3.New(s.getSession())
+
+	result, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(item),
+	})
+	if err != nil {
+		return filesystems.Listing{}, notFound(err)
 	}
 
-	return nil
+	return filesystems.Listing{
+		Etag:         aws.StringValue(result.ETag),
+		LastModified: aws.TimeValue(result.LastModified),
+		Key:          item,
+		Size:         filesystems.SizeInMB(aws.Int64Value(result.ContentLength)),
+	}, nil
+}
+
+func (s *S3) Exists(item string) (bool, error) {
+	_, err := s.Stat(item)
+	if errors.Is(err, filesystems.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *S3) Copy(source, destination string) error {
+	svc := s3.New(s.getSession())
+
+	_, err := svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(s.Bucket),
+		CopySource: aws.String((&url.URL{Path: s.Bucket + "/" + source}).EscapedPath()),
+		Key:        aws.String(destination),
+	})
+	return notFound(err)
+}
+
+func (s *S3) Move(source, destination string) error {
+	if err := s.Copy(source, destination); err != nil {
+		return err
+	}
+
+	svc := s3.New(s.getSession())
+	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(source),
+	})
+	return err
+}
+
+// notFound translates the S3 "no such key" errors into filesystems.ErrNotExist
+func notFound(err error) error {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return fmt.Errorf("%w: %s", filesystems.ErrNotExist, aerr.Message())
+		}
+	}
+	return err
+}
+
+func (s *S3) PresignGet(item string, expires time.Duration) (string, error) {
+	svc := s3.New(s.getSession())
+
+	req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(item),
+	})
+	return req.Presign(expires)
+}
+
+func (s *S3) PresignPut(item string, expires time.Duration) (string, error) {
+	svc := s3.New(s.getSession())
+
+	req, _ := svc.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(item),
+	})
+	return req.Presign(expires)
 }
@@ -0,0 +1,162 @@
+package sftp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var testSFTP SFTP
+var testRoot string
+var testKeyFile string
+var testKnownHosts string
+var testConnections atomic.Int64
+var testListener net.Listener
+
+func TestMain(m *testing.M) {
+	tmp, err := os.MkdirTemp("", "celeritas-sftp")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	testRoot = filepath.Join(tmp, "root")
+	if err := os.MkdirAll(filepath.Join(testRoot, "base"), 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	clientPub, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	clientSSHPub, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	testKeyFile = filepath.Join(tmp, "id_ed25519")
+	if err := os.WriteFile(testKeyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		log.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "test" && string(pass) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("invalid password")
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), clientSSHPub.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown public key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	testListener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	go serveSFTP(testListener, config)
+
+	host, port, _ := net.SplitHostPort(testListener.Addr().String())
+
+	testKnownHosts = filepath.Join(tmp, "known_hosts")
+	line := knownhosts.Line([]string{testListener.Addr().String()}, hostSigner.PublicKey())
+	if err := os.WriteFile(testKnownHosts, []byte(line+"\n"), 0600); err != nil {
+		log.Fatal(err)
+	}
+
+	testSFTP = SFTP{
+		Host:           host,
+		Port:           port,
+		User:           "test",
+		Pass:           "secret",
+		KnownHostsFile: testKnownHosts,
+		BaseDir:        "base",
+	}
+
+	code := m.Run()
+
+	_ = testSFTP.Close()
+	_ = testListener.Close()
+	_ = os.RemoveAll(tmp)
+	os.Exit(code)
+}
+
+// serveSFTP is a minimal SSH server which only offers the sftp subsystem
+func serveSFTP(listener net.Listener, config *ssh.ServerConfig) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(conn net.Conn) {
+			_, channels, requests, err := ssh.NewServerConn(conn, config)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			testConnections.Add(1)
+			go ssh.DiscardRequests(requests)
+
+			for newChannel := range channels {
+				if newChannel.ChannelType() != "session" {
+					_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+					continue
+				}
+
+				channel, requests, err := newChannel.Accept()
+				if err != nil {
+					continue
+				}
+
+				go func(in <-chan *ssh.Request) {
+					for req := range in {
+						ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+						_ = req.Reply(ok, nil)
+					}
+				}(requests)
+
+				server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(testRoot))
+				if err != nil {
+					channel.Close()
+					continue
+				}
+
+				go func() {
+					_ = server.Serve()
+					server.Close()
+				}()
+			}
+		}(conn)
+	}
+}
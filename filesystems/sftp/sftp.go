@@ -1,166 +1,536 @@
 package sftp
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"net"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/sftp"
 	"github.com/s-petr/celeritas/filesystems"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/sync/singleflight"
 )
 
+// SFTP stores files on a remote server over SSH. It authenticates with any
+// combination of password, private key and SSH agent, verifies the server
+// against a known_hosts file and keeps a single long-lived connection per
+// server which is re-established if it drops. Relative paths are resolved
+// against BaseDir. Get and Delete handle up to Concurrency items at once over
+// that connection, reporting to Progress if it is set. Connecting, including
+// the SSH handshake, is given up after Timeout, DefaultTimeout if 0.
 type SFTP struct {
-	Host string
-	User string
-	Pass string
-	Port string
+	Host                string
+	User                string
+	Pass                string
+	Port                string
+	KeyFile             string
+	KeyPassphrase       string
+	UseAgent            bool
+	KnownHostsFile      string
+	InsecureSkipHostKey bool
+	BaseDir             string
+	Concurrency         int
+	Progress            filesystems.ProgressFunc
+	Timeout             time.Duration
 }
 
-func (s *SFTP) getCredentials() (*sftp.Client, error) {
-	addr := fmt.Sprintf("%s:%s", s.Host, s.Port)
+// DefaultTimeout is how long connecting to a server may take
+const DefaultTimeout = 30 * time.Second
 
-	config := &ssh.ClientConfig{
-		User: s.User,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(s.Pass),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+// connection is a pooled SSH connection shared by every SFTP value with the
+// same settings
+type connection struct {
+	ssh    *ssh.Client
+	client *sftp.Client
+	agent  net.Conn
+}
+
+func (c *connection) close() error {
+	err := c.client.Close()
+	_ = c.ssh.Close()
+	if c.agent != nil {
+		_ = c.agent.Close()
 	}
+	return err
+}
 
-	conn, err := ssh.Dial("tcp", addr, config)
-	if err != nil {
-		return nil, err
+var pool = struct {
+	sync.Mutex
+	connections map[string]*connection
+}{connections: make(map[string]*connection)}
+
+// poolKey identifies the connection by every setting used to authenticate
+// and verify it, so that a connection is never shared with settings which
+// would not have been allowed to make it. The settings are hashed to keep
+// secrets out of the key.
+func (s *SFTP) poolKey() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%q|%q|%q|%q|%q|%q|%t|%q|%t",
+		s.Host, s.Port, s.User, s.Pass, s.KeyFile, s.KeyPassphrase,
+		s.UseAgent, s.KnownHostsFile, s.InsecureSkipHostKey)))
+	return hex.EncodeToString(sum[:])
+}
+
+// dials makes sure only one connection is dialed per pool key at a time
+var dials singleflight.Group
+
+// getCredentials returns the pooled client for this server, dialing a new
+// connection if there is none. The pool is not locked while dialing, so a
+// server which cannot be reached only holds up the callers waiting for it.
+func (s *SFTP) getCredentials() (*sftp.Client, error) {
+	key := s.poolKey()
+
+	pool.Lock()
+	conn, ok := pool.connections[key]
+	pool.Unlock()
+	if ok {
+		return conn.client, nil
 	}
 
-	client, err := sftp.NewClient(conn)
+	v, err, _ := dials.Do(key, func() (any, error) {
+		pool.Lock()
+		conn, ok := pool.connections[key]
+		pool.Unlock()
+		if ok {
+			return conn, nil
+		}
+
+		conn, err := s.dial()
+		if err != nil {
+			return nil, err
+		}
+
+		pool.Lock()
+		pool.connections[key] = conn
+		pool.Unlock()
+		return conn, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	cwd, err := client.Getwd()
-	if err != nil {
-		return nil, err
+	return v.(*connection).client, nil
+}
+
+// reset drops the pooled connection if it is still the one client belongs to
+func (s *SFTP) reset(client *sftp.Client) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	if conn, ok := pool.connections[s.poolKey()]; ok && conn.client == client {
+		_ = conn.close()
+		delete(pool.connections, s.poolKey())
 	}
+}
 
-	log.Println("Current working directory:", cwd)
+// Close closes the pooled connection to this server
+func (s *SFTP) Close() error {
+	pool.Lock()
+	defer pool.Unlock()
 
-	return client, nil
+	conn, ok := pool.connections[s.poolKey()]
+	if !ok {
+		return nil
+	}
 
+	delete(pool.connections, s.poolKey())
+	return conn.close()
 }
 
-func (s *SFTP) Put(fileName, folder string) error {
+// do runs fn with the pooled client, reconnecting and retrying once if the
+// connection turns out to have been lost
+func (s *SFTP) do(fn func(client *sftp.Client) error) error {
 	client, err := s.getCredentials()
 	if err != nil {
 		return err
 	}
-	defer client.Close()
 
-	f, err := os.Open(fileName)
-	if err != nil {
+	err = fn(client)
+	if !connectionLost(err) {
 		return err
 	}
-	defer f.Close()
 
-	f2, err := client.Create(fmt.Sprintf("%s/%s", folder, path.Base(fileName)))
+	s.reset(client)
+
+	client, err = s.getCredentials()
 	if err != nil {
 		return err
 	}
-	defer f2.Close()
+	return fn(client)
+}
 
-	_, err = io.Copy(f2, f)
-	return err
+func connectionLost(err error) bool {
+	return errors.Is(err, sftp.ErrSSHFxConnectionLost) ||
+		errors.Is(err, net.ErrClosed)
 }
 
-func (s *SFTP) List(prefix string) ([]filesystems.Listing, error) {
-	var listing []filesystems.Listing
+func (s *SFTP) dial() (*connection, error) {
+	conn := &connection{}
 
-	client, err := s.getCredentials()
+	auth, err := s.authMethods(conn)
 	if err != nil {
-		return listing, err
+		return nil, err
 	}
-	defer client.Close()
 
-	files, err := client.ReadDir(prefix)
+	hostKeyCallback, err := s.hostKeyCallback()
 	if err != nil {
-		return listing, err
+		if conn.agent != nil {
+			conn.agent.Close()
+		}
+		return nil, err
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
 	}
 
-	for _, x := range files {
-		var item filesystems.Listing
+	config := &ssh.ClientConfig{
+		User:            s.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	}
+
+	conn.ssh, err = dialSSH(net.JoinHostPort(s.Host, s.Port), config)
+	if err != nil {
+		if conn.agent != nil {
+			conn.agent.Close()
+		}
+		return nil, err
+	}
 
-		if !strings.HasPrefix(x.Name(), ".") {
-			b := float64(x.Size())
-			kb := b / 1024
-			mb := kb / 1024
-			item.Key = x.Name()
-			item.Size = mb
-			item.LastModified = x.ModTime()
-			item.IsDir = x.IsDir()
-			listing = append(listing, item)
+	conn.client, err = sftp.NewClient(conn.ssh)
+	if err != nil {
+		_ = conn.ssh.Close()
+		if conn.agent != nil {
+			conn.agent.Close()
 		}
+		return nil, err
 	}
 
-	return listing, nil
+	return conn, nil
 }
 
-func (s *SFTP) Delete(itemsToDelete []string) bool {
-	client, err := s.getCredentials()
+// dialSSH connects to addr like ssh.Dial, but gives up on the handshake as
+// well as the TCP connection after config.Timeout
+func dialSSH(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	tcp, err := net.DialTimeout("tcp", addr, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = tcp.SetDeadline(time.Now().Add(config.Timeout))
+	c, chans, reqs, err := ssh.NewClientConn(tcp, addr, config)
 	if err != nil {
-		return false
+		_ = tcp.Close()
+		return nil, err
+	}
+	_ = tcp.SetDeadline(time.Time{})
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+func (s *SFTP) authMethods(conn *connection) ([]ssh.AuthMethod, error) {
+	var auth []ssh.AuthMethod
+
+	if s.KeyFile != "" {
+		key, err := os.ReadFile(s.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		var signer ssh.Signer
+		if s.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(s.KeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if s.UseAgent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, errors.New("SSH agent requested but SSH_AUTH_SOCK is not set")
+		}
+
+		agentConn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, err
+		}
+
+		conn.agent = agentConn
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+	}
+
+	if s.Pass != "" {
+		auth = append(auth, ssh.Password(s.Pass))
+	}
+
+	if len(auth) == 0 {
+		return nil, errors.New("no SFTP authentication method configured")
+	}
+
+	return auth, nil
+}
+
+func (s *SFTP) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if s.InsecureSkipHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
 	}
-	defer client.Close()
 
-	for _, x := range itemsToDelete {
-		deleteErr := client.Remove(x)
-		if deleteErr != nil {
-			return false
+	file := s.KnownHostsFile
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
 		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
 	}
 
-	return true
+	return knownhosts.New(file)
 }
 
-func (s *SFTP) Get(destination string, items ...string) error {
-	client, err := s.getCredentials()
-	if err != nil {
-		return err
+// path resolves item against the base directory
+func (s *SFTP) path(item string) string {
+	if s.BaseDir == "" || path.IsAbs(item) {
+		return item
 	}
-	defer client.Close()
+	return path.Join(s.BaseDir, item)
+}
 
-	for _, item := range items {
-		err := func() error {
-			dstFile, err := os.Create(fmt.Sprintf("%s/%s", destination, path.Base(item)))
-			if err != nil {
-				return err
+func (s *SFTP) Put(fileName, folder string) error {
+	return s.do(func(client *sftp.Client) error {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		f2, err := client.Create(s.path(fmt.Sprintf("%s/%s", folder, path.Base(fileName))))
+		if err != nil {
+			return err
+		}
+		defer f2.Close()
+
+		_, err = io.Copy(f2, f)
+		return err
+	})
+}
+
+// ListsDirectory marks that List names the entries of a single directory
+func (s *SFTP) ListsDirectory() {}
+
+func (s *SFTP) List(prefix string) ([]filesystems.Listing, error) {
+	var listing []filesystems.Listing
+
+	err := s.do(func(client *sftp.Client) error {
+		files, err := client.ReadDir(s.path(prefix))
+		if err != nil {
+			return err
+		}
+
+		for _, x := range files {
+			var item filesystems.Listing
+
+			if !strings.HasPrefix(x.Name(), ".") {
+				item.Key = x.Name()
+				item.Size = filesystems.SizeInMB(x.Size())
+				item.LastModified = x.ModTime()
+				item.IsDir = x.IsDir()
+				listing = append(listing, item)
 			}
-			defer dstFile.Close()
+		}
+		return nil
+	})
+
+	return listing, err
+}
 
-			srcFile, err := client.Open(item)
+func (s *SFTP) Delete(itemsToDelete []string) error {
+	return filesystems.ForEach("delete", itemsToDelete, s.Concurrency, func(item string) error {
+		return notFound(s.do(func(client *sftp.Client) error {
+			return client.Remove(s.path(item))
+		}))
+	})
+}
+
+func (s *SFTP) Get(destination string, items ...string) error {
+	return filesystems.ForEach("get", items, s.Concurrency, func(item string) (err error) {
+		// reported once, after any reconnect and retry
+		var tracker *filesystems.Tracker
+		defer func() { tracker.Done(err) }()
+
+		return notFound(s.do(func(client *sftp.Client) error {
+			srcFile, err := client.Open(s.path(item))
 			if err != nil {
 				return err
 			}
 			defer srcFile.Close()
 
-			_, err = io.Copy(dstFile, srcFile)
+			total := int64(-1)
+			if info, err := srcFile.Stat(); err == nil {
+				total = info.Size()
+			}
+
+			tracker = filesystems.NewTracker(s.Progress, item, total)
+
+			dstFile, err := os.Create(fmt.Sprintf("%s/%s", destination, path.Base(item)))
 			if err != nil {
 				return err
 			}
+			defer dstFile.Close()
 
-			err = dstFile.Sync()
-			if err != nil {
+			if _, err := io.Copy(tracker.Writer(dstFile), srcFile); err != nil {
 				return err
 			}
-			return nil
-		}()
 
-		if err != nil {
+			return dstFile.Sync()
+		}))
+	})
+}
+
+func (s *SFTP) Open(item string) (io.ReadCloser, error) {
+	var f *sftp.File
+
+	err := s.do(func(client *sftp.Client) (err error) {
+		f, err = client.Open(s.path(item))
+		return err
+	})
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return f, nil
+}
+
+// Create writes item to a hidden temporary file, which Close renames to
+// item and Abort removes, so that item is never left half written
+func (s *SFTP) Create(item string) (io.WriteCloser, error) {
+	var (
+		f      *sftp.File
+		client *sftp.Client
+	)
+
+	p := s.path(item)
+	temp := path.Join(path.Dir(p), fmt.Sprintf(".%s.%d.part", path.Base(p), time.Now().UnixNano()))
+
+	err := s.do(func(c *sftp.Client) (err error) {
+		if err := c.MkdirAll(path.Dir(p)); err != nil {
 			return err
 		}
+
+		client = c
+		f, err = c.Create(temp)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &tempFile{File: f, client: client, path: p}, nil
+}
+
+type tempFile struct {
+	*sftp.File
+	client *sftp.Client
+	path   string
+}
+
+func (f *tempFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		_ = f.client.Remove(f.Name())
+		return err
 	}
+	return f.client.PosixRename(f.Name(), f.path)
+}
+
+func (f *tempFile) Abort(error) error {
+	_ = f.File.Close()
+	return f.client.Remove(f.Name())
+}
+
+func (s *SFTP) Stat(item string) (filesystems.Listing, error) {
+	var info os.FileInfo
+
+	err := s.do(func(client *sftp.Client) (err error) {
+		info, err = client.Stat(s.path(item))
+		return err
+	})
+	if err != nil {
+		return filesystems.Listing{}, notFound(err)
+	}
+
+	return filesystems.Listing{
+		LastModified: info.ModTime(),
+		Key:          item,
+		Size:         filesystems.SizeInMB(info.Size()),
+		IsDir:        info.IsDir(),
+	}, nil
+}
+
+func (s *SFTP) Exists(item string) (bool, error) {
+	_, err := s.Stat(item)
+	if errors.Is(err, filesystems.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
 
-	return nil
+func (s *SFTP) Copy(source, destination string) error {
+	return notFound(s.do(func(client *sftp.Client) error {
+		srcFile, err := client.Open(s.path(source))
+		if err != nil {
+			return err
+		}
+		defer srcFile.Close()
+
+		if err := client.MkdirAll(path.Dir(s.path(destination))); err != nil {
+			return err
+		}
+
+		dstFile, err := client.Create(s.path(destination))
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(dstFile, srcFile); err != nil {
+			_ = dstFile.Close()
+			return err
+		}
+		return dstFile.Close()
+	}))
+}
+
+func (s *SFTP) Move(source, destination string) error {
+	return notFound(s.do(func(client *sftp.Client) error {
+		if err := client.MkdirAll(path.Dir(s.path(destination))); err != nil {
+			return err
+		}
+
+		return client.PosixRename(s.path(source), s.path(destination))
+	}))
+}
+
+// notFound translates missing file errors into filesystems.ErrNotExist
+func notFound(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", filesystems.ErrNotExist, err.Error())
+	}
+	return err
 }
@@ -0,0 +1,236 @@
+package sftp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/s-petr/celeritas/filesystems"
+)
+
+func TestSFTP_ConnectionReuse(t *testing.T) {
+	_ = testSFTP.Close()
+	before := testConnections.Load()
+
+	for i := 0; i < 3; i++ {
+		if _, err := testSFTP.List("."); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if opened := testConnections.Load() - before; opened != 1 {
+		t.Errorf("expected 1 connection to be opened, got %d", opened)
+	}
+}
+
+func TestSFTP_Reconnect(t *testing.T) {
+	if _, err := testSFTP.List("."); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := testSFTP.getCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a dropped connection
+	pool.Lock()
+	_ = pool.connections[testSFTP.poolKey()].ssh.Close()
+	pool.Unlock()
+	_ = client.Wait()
+
+	if _, err := testSFTP.List("."); err != nil {
+		t.Error("expected the connection to be re-established, got", err)
+	}
+}
+
+func TestSFTP_CreateOpenBaseDir(t *testing.T) {
+	w, err := testSFTP.Create("docs/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(w, "hello world"); err != nil {
+		t.Error(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(testRoot, "base", "docs", "hello.txt")); err != nil {
+		t.Error("expected file to be created in the base directory", err)
+	}
+
+	r, err := testSFTP.Open("docs/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(data) != "hello world" {
+		t.Errorf("expected \"hello world\", got %q", string(data))
+	}
+
+	if _, err := testSFTP.Stat("docs/missing.txt"); !errors.Is(err, filesystems.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestSFTP_CreateAbort(t *testing.T) {
+	w, err := testSFTP.Create("docs/aborted.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(w, "trunc"); err != nil {
+		t.Error(err)
+	}
+
+	if err := filesystems.Abort(w, errors.New("client went away")); err != nil {
+		t.Error(err)
+	}
+
+	if exists, err := testSFTP.Exists("docs/aborted.txt"); err != nil || exists {
+		t.Errorf("expected an aborted write to create nothing, got %v, %v", exists, err)
+	}
+}
+
+func TestSFTP_KeyAuth(t *testing.T) {
+	s := testSFTP
+	s.Pass = ""
+	s.KeyFile = testKeyFile
+	defer s.Close()
+
+	if _, err := s.List("."); err != nil {
+		t.Error("key authentication failed:", err)
+	}
+}
+
+func TestSFTP_HostKeyVerification(t *testing.T) {
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHosts, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pooled connection of testSFTP was verified against another
+	// known_hosts file, and must not be reused
+	if _, err := testSFTP.List("."); err != nil {
+		t.Fatal(err)
+	}
+
+	s := testSFTP
+	s.KnownHostsFile = knownHosts
+	defer s.Close()
+
+	_, err := s.List(".")
+	if err == nil || !strings.Contains(err.Error(), "knownhosts") {
+		t.Errorf("expected host key verification to fail, got %v", err)
+	}
+}
+
+func TestSFTP_ConcurrentGetDelete(t *testing.T) {
+	var items []string
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		item := "batch/" + name + ".txt"
+		items = append(items, item)
+
+		w, err := testSFTP.Create(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.WriteString(w, name)
+		_ = w.Close()
+	}
+
+	var reported atomic.Int32
+	s := testSFTP
+	s.Concurrency = 3
+	s.Progress = func(p filesystems.Progress) {
+		if p.Done && p.Err == nil {
+			reported.Add(1)
+		}
+	}
+
+	destination := t.TempDir()
+	if err := s.Get(destination, items...); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := reported.Load(); n != int32(len(items)) {
+		t.Errorf("expected %d completed transfers, got %d", len(items), n)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(destination, "c.txt")); string(data) != "c" {
+		t.Errorf("expected \"c\", got %q", string(data))
+	}
+
+	err := s.Delete(append(items, "batch/missing.txt"))
+
+	var multi *filesystems.MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 {
+		t.Fatalf("expected one aggregated error, got %v", err)
+	}
+
+	if !errors.Is(err, filesystems.ErrNotExist) {
+		t.Error("expected ErrNotExist for the missing item, got", err)
+	}
+
+	if exists, _ := s.Exists("batch/a.txt"); exists {
+		t.Error("batch/a.txt found but it should have been deleted")
+	}
+}
+
+func TestSFTP_DialTimeout(t *testing.T) {
+	// accepts connections but never completes the SSH handshake
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(silent.Addr().String())
+	s := SFTP{Host: host, Port: port, Pass: "secret", InsecureSkipHostKey: true, Timeout: 500 * time.Millisecond}
+
+	failed := make(chan error, 1)
+	go func() {
+		_, err := s.List(".")
+		failed <- err
+	}()
+
+	// other servers are not held up while the silent one is dialed
+	time.Sleep(100 * time.Millisecond)
+	if _, err := testSFTP.List("."); err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case err := <-failed:
+		if err == nil {
+			t.Error("expected connecting to a silent server to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected connecting to a silent server to time out")
+	}
+}
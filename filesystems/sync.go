@@ -0,0 +1,213 @@
+package filesystems
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SyncOptions control how Sync mirrors one file system onto another
+type SyncOptions struct {
+	// DryRun reports what would be transferred without changing anything
+	DryRun bool
+	// Delete removes items from the destination which are not in the source
+	Delete bool
+	// Concurrency is the number of items copied at once, DefaultConcurrency
+	// if not set
+	Concurrency int
+	// Progress, if set, is called as each item is copied
+	Progress ProgressFunc
+}
+
+// SyncAction describes a single change made, or planned, by Sync
+type SyncAction struct {
+	Key    string
+	Delete bool
+	Reason string
+}
+
+// Sync copies every item under prefix on from to the same key on to,
+// skipping items whose size, etag and modification time show that the
+// destination is already up to date. Etags are only compared between file
+// systems of the same type, since drivers compute them differently. It returns the actions taken, or the
+// actions that would be taken if opts.DryRun is set.
+func Sync(from, to FS, prefix string, opts SyncOptions) ([]SyncAction, error) {
+	var actions []SyncAction
+
+	source, found, err := walk(from, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// with nothing to compare against, every item at the destination would
+	// look stale
+	if opts.Delete && !found {
+		return nil, fmt.Errorf("sync: %s not found on the source, not deleting from the destination", prefix)
+	}
+
+	destination, _, err := walk(to, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	sameDriver := reflect.TypeOf(from) == reflect.TypeOf(to)
+
+	var keys []string
+	for _, key := range sortedKeys(source) {
+		reason := changed(*source[key], destination[key], sameDriver)
+		if reason == "" {
+			continue
+		}
+
+		keys = append(keys, key)
+		actions = append(actions, SyncAction{Key: key, Reason: reason})
+	}
+
+	if !opts.DryRun {
+		err := ForEach("copy", keys, opts.Concurrency, func(key string) error {
+			return transfer(from, to, key, opts.Progress)
+		})
+		if err != nil {
+			return actions, err
+		}
+	}
+
+	if opts.Delete {
+		var stale []string
+		for _, key := range sortedKeys(destination) {
+			if _, ok := source[key]; ok {
+				continue
+			}
+
+			stale = append(stale, key)
+			actions = append(actions, SyncAction{Key: key, Delete: true, Reason: "not in source"})
+		}
+
+		if !opts.DryRun && len(stale) > 0 {
+			if err := to.Delete(stale); err != nil {
+				return actions, err
+			}
+		}
+	}
+
+	return actions, nil
+}
+
+// changed returns why dst needs to be replaced by src, or an empty string if
+// it is up to date. Etags are compared only if compareEtags is set.
+func changed(src Listing, dst *Listing, compareEtags bool) string {
+	srcEtag, dstEtag := "", ""
+	if compareEtags && dst != nil {
+		srcEtag, dstEtag = strings.Trim(src.Etag, `"`), strings.Trim(dst.Etag, `"`)
+	}
+
+	switch {
+	case dst == nil:
+		return "missing"
+	case src.Size != dst.Size:
+		return "size"
+	case srcEtag != "" && dstEtag != "" && srcEtag != dstEtag:
+		return "etag"
+	case src.LastModified.After(dst.LastModified):
+		return "modified"
+	}
+	return ""
+}
+
+func transfer(from, to FS, key string, progress ProgressFunc) (err error) {
+	total := int64(-1)
+	if progress != nil {
+		if info, err := from.Stat(key); err == nil {
+			total = int64(math.Round(info.Size * 1024 * 1024))
+		}
+	}
+
+	tracker := NewTracker(progress, key, total)
+	defer func() { tracker.Done(err) }()
+
+	r, err := from.Open(key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := to.Create(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, tracker.Reader(r)); err != nil {
+		_ = Abort(w, err)
+		return err
+	}
+
+	return w.Close()
+}
+
+// walk lists every file under prefix, keyed by its full path, and reports
+// whether prefix was found. Any error but a missing prefix fails the walk,
+// so that a partial listing is never mistaken for a complete one. A
+// DirLister is walked one directory at a time, joining the names it lists
+// to their directory, so all drivers can be compared on the same keys.
+func walk(fs FS, prefix string) (map[string]*Listing, bool, error) {
+	items := make(map[string]*Listing)
+	_, byDirectory := fs.(DirLister)
+
+	var add func(prefix string, listing []Listing) error
+	add = func(prefix string, listing []Listing) error {
+		for i := range listing {
+			item := listing[i]
+
+			if !byDirectory {
+				if !item.IsDir {
+					items[item.Key] = &item
+				}
+				continue
+			}
+
+			item.Key = path.Join(prefix, item.Key)
+			if item.IsDir {
+				listing, err := fs.List(item.Key)
+				if err != nil {
+					return err
+				}
+				if err := add(item.Key, listing); err != nil {
+					return err
+				}
+				continue
+			}
+
+			items[item.Key] = &item
+		}
+		return nil
+	}
+
+	listing, err := fs.List(prefix)
+	if err != nil {
+		// a prefix that does not exist yet simply has nothing in it
+		if errors.Is(err, ErrNotExist) || errors.Is(err, os.ErrNotExist) {
+			return items, false, nil
+		}
+		return nil, false, err
+	}
+
+	if err := add(prefix, listing); err != nil {
+		return nil, false, err
+	}
+	return items, true, nil
+}
+
+func sortedKeys(items map[string]*Listing) []string {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,194 @@
+package filesystems_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/s-petr/celeritas/filesystems"
+	"github.com/s-petr/celeritas/filesystems/local"
+)
+
+func writeFile(t *testing.T, fs filesystems.FS, item, content string) {
+	w, err := fs.Create(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(w, content); err != nil {
+		t.Error(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	from := &local.Local{Root: t.TempDir()}
+	to := &local.Local{Root: t.TempDir()}
+
+	writeFile(t, from, "backup/one.txt", "one")
+	writeFile(t, from, "backup/nested/two.txt", "two")
+	writeFile(t, from, "other/three.txt", "three")
+	writeFile(t, to, "backup/stale.txt", "stale")
+
+	actions, err := filesystems.Sync(from, to, "backup/", filesystems.SyncOptions{DryRun: true, Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(actions) != 3 {
+		t.Errorf("expected 3 planned actions, got %+v", actions)
+	}
+
+	if exists, _ := to.Exists("backup/one.txt"); exists {
+		t.Error("dry run should not transfer any files")
+	}
+
+	if _, err := filesystems.Sync(from, to, "backup/", filesystems.SyncOptions{Delete: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range []string{"backup/one.txt", "backup/nested/two.txt"} {
+		if exists, _ := to.Exists(item); !exists {
+			t.Errorf("%s not found in destination but it should be there", item)
+		}
+	}
+
+	for _, item := range []string{"backup/stale.txt", "other/three.txt"} {
+		if exists, _ := to.Exists(item); exists {
+			t.Errorf("%s found in destination but it should not be there", item)
+		}
+	}
+
+	actions, err = filesystems.Sync(from, to, "backup/", filesystems.SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(actions) != 0 {
+		t.Errorf("expected nothing to sync, got %+v", actions)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	writeFile(t, from, "backup/one.txt", "ONE")
+
+	actions, err = filesystems.Sync(from, to, "backup/", filesystems.SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(actions) != 1 || actions[0].Key != "backup/one.txt" {
+		t.Errorf("expected only backup/one.txt to be synced, got %+v", actions)
+	}
+}
+
+// failingFS fails every read after the first few bytes
+type failingFS struct {
+	filesystems.FS
+}
+
+func (f failingFS) Open(item string) (io.ReadCloser, error) {
+	r, err := f.FS.Open(item)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(io.LimitReader(r, 2), iotest.ErrReader(errors.New("disk error"))), r}, nil
+}
+
+func TestSync_FailedTransfer(t *testing.T) {
+	from := &local.Local{Root: t.TempDir()}
+	to := &local.Local{Root: t.TempDir()}
+
+	writeFile(t, from, "backup/one.txt", "one")
+
+	if _, err := filesystems.Sync(failingFS{from}, to, "backup/", filesystems.SyncOptions{}); err == nil {
+		t.Error("expected the failed transfer to be reported")
+	}
+
+	if exists, _ := to.Exists("backup/one.txt"); exists {
+		t.Error("expected a failed transfer to leave nothing at the destination")
+	}
+}
+
+// missingFS has nothing under any prefix
+type missingFS struct {
+	filesystems.FS
+}
+
+func (missingFS) List(prefix string) ([]filesystems.Listing, error) {
+	return nil, filesystems.ErrNotExist
+}
+
+func TestSync_MissingSource(t *testing.T) {
+	to := &local.Local{Root: t.TempDir()}
+	writeFile(t, to, "backup/one.txt", "one")
+
+	if _, err := filesystems.Sync(missingFS{to}, to, "backup/", filesystems.SyncOptions{Delete: true}); err == nil {
+		t.Error("expected deleting against a missing source to be refused")
+	}
+
+	if exists, _ := to.Exists("backup/one.txt"); !exists {
+		t.Error("expected the destination to be left alone")
+	}
+
+	if actions, err := filesystems.Sync(missingFS{to}, to, "backup/", filesystems.SyncOptions{}); err != nil || len(actions) != 0 {
+		t.Errorf("expected nothing to copy from a missing source, got %+v, %v", actions, err)
+	}
+}
+
+// dirFS lists a single directory by name, as the sftp and webdav drivers do
+type dirFS struct {
+	*local.Local
+}
+
+func (dirFS) ListsDirectory() {}
+
+func (d dirFS) List(prefix string) ([]filesystems.Listing, error) {
+	entries, err := os.ReadDir(filepath.Join(d.Root, prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	var listing []filesystems.Listing
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		listing = append(listing, filesystems.Listing{
+			Key:          entry.Name(),
+			Size:         filesystems.SizeInMB(info.Size()),
+			LastModified: info.ModTime(),
+			IsDir:        entry.IsDir(),
+		})
+	}
+	return listing, nil
+}
+
+func TestSync_DirLister(t *testing.T) {
+	from := dirFS{&local.Local{Root: t.TempDir()}}
+	to := &local.Local{Root: t.TempDir()}
+
+	// names starting like the prefix are still joined to their directory
+	writeFile(t, from, "backups/backups.tar", "tar")
+	writeFile(t, from, "backups/daily/backups-1.tar", "one")
+
+	if _, err := filesystems.Sync(from, to, "backups", filesystems.SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range []string{"backups/backups.tar", "backups/daily/backups-1.tar"} {
+		if exists, _ := to.Exists(item); !exists {
+			t.Errorf("%s not found in destination but it should be there", item)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package filesystems
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultConcurrency is the number of items transferred in parallel when a
+// driver's Concurrency is not set
+const DefaultConcurrency = 4
+
+// Progress reports how far the transfer of a single item has got. Total is
+// -1 when the size is not known in advance. Done is set on the final report
+// for an item, along with Err if the transfer failed.
+type Progress struct {
+	Item  string
+	Bytes int64
+	Total int64
+	Done  bool
+	Err   error
+}
+
+// ProgressFunc receives progress reports. It may be called concurrently
+// from several goroutines when more than one item is being transferred.
+type ProgressFunc func(Progress)
+
+// PathError records a failed operation on a single item
+type PathError struct {
+	Op  string
+	Key string
+	Err error
+}
+
+func (e *PathError) Error() string {
+	if e.Err == nil {
+		return e.Op + " " + e.Key + ": failed"
+	}
+	return e.Op + " " + e.Key + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError collects the errors of a multi-item operation, one per failed
+// item
+type MultiError struct {
+	Errors []*PathError
+}
+
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d items failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// ForEach runs fn for every item with at most concurrency running at once.
+// Every item is attempted; failures are returned together as a *MultiError.
+func ForEach(op string, items []string, concurrency int, fn func(item string) error) error {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	var mu sync.Mutex
+	var errs []*PathError
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(item string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(item); err != nil {
+				mu.Lock()
+				errs = append(errs, &PathError{Op: op, Key: item, Err: err})
+				mu.Unlock()
+			}
+		}(item)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &MultiError{Errors: errs}
+	}
+	return nil
+}
+
+// Tracker counts the bytes transferred for one item and passes them on to a
+// ProgressFunc. A nil ProgressFunc makes every method a no-op.
+type Tracker struct {
+	item     string
+	total    int64
+	bytes    atomic.Int64
+	progress ProgressFunc
+}
+
+// NewTracker starts tracking the transfer of item
+func NewTracker(progress ProgressFunc, item string, total int64) *Tracker {
+	return &Tracker{item: item, total: total, progress: progress}
+}
+
+// Add records n more bytes as transferred
+func (t *Tracker) Add(n int64) {
+	if t == nil || t.progress == nil || n == 0 {
+		return
+	}
+	t.progress(Progress{Item: t.item, Bytes: t.bytes.Add(n), Total: t.total})
+}
+
+// Done sends the final report for the item
+func (t *Tracker) Done(err error) {
+	if t == nil || t.progress == nil {
+		return
+	}
+	t.progress(Progress{Item: t.item, Bytes: t.bytes.Load(), Total: t.total, Done: true, Err: err})
+}
+
+// Read counts bytes as they are read through the tracker; this matches the
+// progress reader expected by minio.PutObjectOptions
+func (t *Tracker) Read(p []byte) (int, error) {
+	t.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// Reader wraps r so that every byte read from it is tracked
+func (t *Tracker) Reader(r io.Reader) io.Reader {
+	return &trackedReader{r: r, t: t}
+}
+
+// Writer wraps w so that every byte written to it is tracked
+func (t *Tracker) Writer(w io.Writer) io.Writer {
+	return &trackedWriter{w: w, t: t}
+}
+
+// WriterAt wraps w so that every byte written to it is tracked
+func (t *Tracker) WriterAt(w io.WriterAt) io.WriterAt {
+	return &trackedWriterAt{w: w, t: t}
+}
+
+type trackedReader struct {
+	r io.Reader
+	t *Tracker
+}
+
+func (r *trackedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.t.Add(int64(n))
+	return n, err
+}
+
+type trackedWriter struct {
+	w io.Writer
+	t *Tracker
+}
+
+func (w *trackedWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.t.Add(int64(n))
+	return n, err
+}
+
+type trackedWriterAt struct {
+	w io.WriterAt
+	t *Tracker
+}
+
+func (w *trackedWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.w.WriteAt(p, off)
+	w.t.Add(int64(n))
+	return n, err
+}
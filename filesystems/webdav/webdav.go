@@ -1,6 +1,7 @@
 package webdav
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,10 +12,14 @@ import (
 	"github.com/studio-b12/gowebdav"
 )
 
+// WebDAV stores files on a WebDAV server. Get and Delete handle up to
+// Concurrency items at once, reporting to Progress if it is set.
 type WebDAV struct {
-	Host string
-	User string
-	Pass string
+	Host        string
+	User        string
+	Pass        string
+	Concurrency int
+	Progress    filesystems.ProgressFunc
 }
 
 func (w *WebDAV) getCredentials() *gowebdav.Client {
@@ -30,11 +35,13 @@ func (w *WebDAV) Put(fileName, folder string) error {
 	}
 	defer file.Close()
 
-	err = client.WriteStream(fmt.Sprintf("%s/%s",
+	return client.WriteStream(fmt.Sprintf("%s/%s",
 		folder, path.Base(fileName)), file, 0664)
-	return nil
 }
 
+// ListsDirectory marks that List names the entries of a single directory
+func (w *WebDAV) ListsDirectory() {}
+
 func (w *WebDAV) List(prefix string) ([]filesystems.Listing, error) {
 	var listing []filesystems.Listing
 
@@ -63,49 +70,107 @@ func (w *WebDAV) List(prefix string) ([]filesystems.Listing, error) {
 	return listing, nil
 }
 
-func (w *WebDAV) Delete(itemsToDelete []string) bool {
+func (w *WebDAV) Delete(itemsToDelete []string) error {
 	client := w.getCredentials()
 
-	for _, item := range itemsToDelete {
-		if err := client.Remove(item); err != nil {
-			return false
-		}
-
-	}
-	return true
+	return filesystems.ForEach("delete", itemsToDelete, w.Concurrency, func(item string) error {
+		return notFound(client.Remove(item))
+	})
 }
 
 func (w *WebDAV) Get(destination string, items ...string) error {
 	client := w.getCredentials()
 
-	for _, item := range items {
-		err := func() error {
-			webDAVFilePath := item
-			localFilePath := fmt.Sprintf("%s/%s",
-				destination, path.Base(item))
-
-			reader, err := client.ReadStream(webDAVFilePath)
-			if err != nil {
-				return err
+	return filesystems.ForEach("get", items, w.Concurrency, func(item string) (err error) {
+		total := int64(-1)
+		if w.Progress != nil {
+			if info, err := client.Stat(item); err == nil {
+				total = info.Size()
 			}
+		}
 
-			file, err := os.Create(localFilePath)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
+		tracker := filesystems.NewTracker(w.Progress, item, total)
+		defer func() { tracker.Done(err) }()
 
-			_, err = io.Copy(file, reader)
-			if err != nil {
-				return err
-			}
+		reader, err := client.ReadStream(item)
+		if err != nil {
+			return notFound(err)
+		}
+		defer reader.Close()
 
-			return nil
-		}()
+		file, err := os.Create(fmt.Sprintf("%s/%s", destination, path.Base(item)))
 		if err != nil {
 			return err
 		}
+		defer file.Close()
+
+		_, err = io.Copy(tracker.Writer(file), reader)
+		return err
+	})
+}
+
+func (w *WebDAV) Open(item string) (io.ReadCloser, error) {
+	client := w.getCredentials()
+
+	reader, err := client.ReadStream(item)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return reader, nil
+}
+
+func (w *WebDAV) Create(item string) (io.WriteCloser, error) {
+	client := w.getCredentials()
+
+	return filesystems.NewPipeWriter(func(r io.Reader) error {
+		return client.WriteStream(item, r, 0664)
+	}), nil
+}
+
+func (w *WebDAV) Stat(item string) (filesystems.Listing, error) {
+	client := w.getCredentials()
+
+	info, err := client.Stat(item)
+	if err != nil {
+		return filesystems.Listing{}, notFound(err)
+	}
+
+	listing := filesystems.Listing{
+		LastModified: info.ModTime(),
+		Key:          item,
+		Size:         filesystems.SizeInMB(info.Size()),
+		IsDir:        info.IsDir(),
+	}
+
+	if f, ok := info.(*gowebdav.File); ok {
+		listing.Etag = f.ETag()
+	}
+
+	return listing, nil
+}
+
+func (w *WebDAV) Exists(item string) (bool, error) {
+	_, err := w.Stat(item)
+	if errors.Is(err, filesystems.ErrNotExist) {
+		return false, nil
 	}
+	return err == nil, err
+}
 
-	return nil
+func (w *WebDAV) Copy(source, destination string) error {
+	client := w.getCredentials()
+	return notFound(client.Copy(source, destination, true))
+}
+
+func (w *WebDAV) Move(source, destination string) error {
+	client := w.getCredentials()
+	return notFound(client.Rename(source, destination, true))
+}
+
+// notFound translates missing file errors into filesystems.ErrNotExist
+func notFound(err error) error {
+	if err != nil && gowebdav.IsErrNotFound(err) {
+		return fmt.Errorf("%w: %s", filesystems.ErrNotExist, err.Error())
+	}
+	return err
 }
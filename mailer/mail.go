@@ -29,6 +29,14 @@ type Mail struct {
 	API         string
 	APIKey      string
 	APIURL      string
+	// Transport, if set, delivers every message instead of SMTP or an API,
+	// for example to capture mail in tests
+	Transport Transport
+}
+
+// Transport delivers a message once its bodies have been rendered
+type Transport interface {
+	Deliver(msg Message, html, plain string) error
 }
 
 type Message struct {
@@ -58,6 +66,10 @@ func (m *Mail) ListenForMail() {
 }
 
 func (m *Mail) Send(msg Message) error {
+	if m.Transport != nil {
+		return m.deliver(msg)
+	}
+
 	if m.API != "smtp" &&
 		len(m.API) > 0 &&
 		len(m.APIKey) > 0 &&
@@ -217,6 +229,20 @@ func (m *Mail) SendSMTPMessage(msg Message) error {
 	return nil
 }
 
+func (m *Mail) deliver(msg Message) error {
+	formattedMessage, err := m.buildHTMLMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	plainMessage, err := m.buildPlainTextMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	return m.Transport.Deliver(msg, formattedMessage, plainMessage)
+}
+
 func (m *Mail) getEncryption(e string) mail.Encryption {
 	switch e {
 	case "tls":
@@ -105,3 +105,31 @@ func TestMail_ChooseAPI(t *testing.T) {
 	}
 
 }
+
+type capture struct {
+	msg         Message
+	html, plain string
+}
+
+func (c *capture) Deliver(msg Message, html, plain string) error {
+	c.msg, c.html, c.plain = msg, html, plain
+	return nil
+}
+
+func TestMail_Transport(t *testing.T) {
+	var got capture
+
+	m := mailer
+	m.Transport = &got
+
+	if err := m.Send(getTestMsg()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.msg.To != "recipient@test.com" {
+		t.Error("transport did not get the message")
+	}
+	if got.html == "" || got.plain == "" {
+		t.Error("transport did not get the rendered bodies")
+	}
+}
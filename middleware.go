@@ -10,24 +10,51 @@ import (
 )
 
 func (c *Celeritas) SessionLoad(next http.Handler) http.Handler {
+	if c.Session == nil {
+		return next
+	}
 	return c.Session.LoadAndSave(next)
 }
 
 func (c *Celeritas) NoSurf(next http.Handler) http.Handler {
+	opts := c.config.csrf
+	if opts.HeaderMode {
+		next = c.csrfHeaderMode(next)
+	}
+
 	csrfHandler := nosurf.New(next)
 	secure, _ := strconv.ParseBool(c.config.cookie.secure)
 
-	csrfHandler.ExemptGlob("/api/*")
+	for _, glob := range opts.Exempt {
+		csrfHandler.ExemptGlob(glob)
+	}
+	csrfHandler.ExemptRegexp("^" + signedFilesPath + "/")
+
+	// NoSurf runs before SessionLoad, so load the session for failure pages
+	var failure http.Handler = http.HandlerFunc(c.csrfFailed)
+	if c.Session != nil {
+		failure = c.Session.LoadAndSave(failure)
+	}
+	csrfHandler.SetFailureHandler(failure)
 
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
 		Path:     "/",
 		Secure:   secure,
-		SameSite: http.SameSiteStrictMode,
+		SameSite: opts.SameSite,
 		Domain:   c.config.cookie.domain,
 	})
 
-	return csrfHandler
+	if !opts.HeaderMode {
+		return csrfHandler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.Header.Get(csrfHeaderAlias); token != "" && r.Header.Get(nosurf.HeaderName) == "" {
+			r.Header.Set(nosurf.HeaderName, token)
+		}
+		csrfHandler.ServeHTTP(w, r)
+	})
 }
 
 func (c *Celeritas) CheckForMaintenanceMode(next http.Handler) http.Handler {
@@ -0,0 +1,113 @@
+package celeritas
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/s-petr/celeritas/cache"
+	"github.com/s-petr/celeritas/filesystems"
+	"github.com/s-petr/celeritas/mailer"
+)
+
+// Options change how New sets up an application. The zero value sets up
+// every subsystem from .env and the config files.
+type Options struct {
+	// DB, if set, is used instead of connecting to DATABASE_TYPE
+	DB *Database
+	// Cache, if set, is used instead of the CACHE driver. If it is also a
+	// cache.Locker it provides the locks too.
+	Cache cache.Cache
+	// Mailer, if set, is used instead of the mailer configured by the SMTP_
+	// and MAILER_ settings; New starts its ListenForMail
+	Mailer *mailer.Mail
+	// Session, if set, is used instead of the SESSION_TYPE store
+	Session *scs.SessionManager
+	// FileSystems, if set, is used instead of the disks configured in .env
+	FileSystems *filesystems.Registry
+
+	// DisableDB, DisableCache and so on skip setting up a subsystem, leaving
+	// its field nil. A disabled mailer answers every message sent to it with
+	// ErrMailDisabled.
+	DisableDB          bool
+	DisableCache       bool
+	DisableMailer      bool
+	DisableSession     bool
+	DisableFileSystems bool
+
+	// Retry overrides the STARTUP_ settings for connecting to the database
+	// and Redis
+	Retry RetryOptions
+}
+
+// ErrMailDisabled is the result of mail sent while the mailer is disabled
+var ErrMailDisabled = errors.New("mailer is disabled")
+
+// RetryOptions control how connecting to a service is retried at startup.
+// Zero fields take their value from the STARTUP_ settings.
+type RetryOptions struct {
+	// Attempts is how many times to try connecting
+	Attempts int
+	// Backoff is the wait after the first failed attempt, doubled after each
+	// further one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// retryOptions fills the zero fields of o.Retry from the STARTUP_ settings
+func (c *Celeritas) retryOptions(o Options) RetryOptions {
+	retry := o.Retry
+	if retry.Attempts == 0 {
+		retry.Attempts = c.Settings.Startup.Attempts
+	}
+	if retry.Backoff == 0 {
+		retry.Backoff = c.Settings.Startup.Backoff
+	}
+	if retry.MaxBackoff == 0 {
+		retry.MaxBackoff = c.Settings.Startup.MaxBackoff
+	}
+	return retry
+}
+
+// retry calls connect until it succeeds or has failed opts.Attempts times,
+// and returns the last error
+func (c *Celeritas) retry(service string, opts RetryOptions, connect func() error) error {
+	wait := opts.Backoff
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil || attempt >= opts.Attempts {
+			return err
+		}
+
+		c.InfoLog.Printf("%s unavailable (attempt %d of %d), retrying in %s: %v",
+			service, attempt, opts.Attempts, wait, err)
+		time.Sleep(wait)
+		wait = min(wait*2, opts.MaxBackoff)
+	}
+}
+
+func pingRedis(pool *redis.Pool) error {
+	conn := pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("PING")
+	return err
+}
+
+// disabledMailer answers every message with ErrMailDisabled, so that code
+// waiting on Mail.Results does not block forever
+func disabledMailer() mailer.Mail {
+	m := mailer.Mail{
+		Jobs:    make(chan mailer.Message, 20),
+		Results: make(chan mailer.Result, 20),
+	}
+
+	go func() {
+		for range m.Jobs {
+			m.Results <- mailer.Result{Success: false, Error: ErrMailDisabled}
+		}
+	}()
+
+	return m
+}
@@ -0,0 +1,307 @@
+package celeritas
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// DefaultPageCacheTTL is how many seconds a page is cached for when neither
+// PageCacheOptions.TTL nor the response's Cache-Control gives a lifetime
+const DefaultPageCacheTTL = 60
+
+// DefaultPageCacheMaxBody is the largest response body, in bytes, which is
+// cached
+const DefaultPageCacheMaxBody = 1 << 20
+
+const pageCachePrefix = "page-cache:"
+
+// PageCacheOptions configure the PageCache middleware
+type PageCacheOptions struct {
+	// TTL is how many seconds a page is cached for, DefaultPageCacheTTL if 0.
+	// A max-age or s-maxage in the response's Cache-Control takes precedence.
+	TTL int
+	// Vary lists request headers whose values are part of the cache key, such
+	// as Accept-Language
+	Vary []string
+	// MaxBody is the largest body, in bytes, which is cached,
+	// DefaultPageCacheMaxBody if 0
+	MaxBody int
+	// Tags returns extra tags to store a page under, which PurgePageTags can
+	// later remove it by
+	Tags func(r *http.Request) []string
+	// Skip, if set, bypasses the cache for requests it returns true for
+	Skip func(r *http.Request) bool
+}
+
+// cachedPage is a complete response as stored in the cache
+type cachedPage struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	Stored int64
+}
+
+// PageCache returns middleware which stores complete GET and HEAD responses
+// in c.Cache and serves later requests for the same page from there.
+// Requests from logged in users or with an Authorization header are never
+// cached or served from the cache, nor are responses which set cookies or
+// whose Cache-Control forbids it. Headers named by a response's Vary are not
+// part of the key; list them in PageCacheOptions.Vary instead.
+//
+// Pages sent with a Content-Security-Policy nonce are never cached, so with
+// a {nonce} in CONTENT_SECURITY_POLICY nothing is. Neither is anything under
+// NoSurf in header mode, which sets the XSRF-TOKEN cookie on every response.
+// A warning is logged if PageCache is built with either.
+//
+// It must be used after SessionLoad. Pages containing CSRF tokens should
+// not be cached, since every visitor would be sent the same token.
+func (c *Celeritas) PageCache(opts ...PageCacheOptions) func(http.Handler) http.Handler {
+	var opt PageCacheOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if strings.Contains(c.config.securityHeaders.CSP, "{nonce}") {
+		c.ErrorLog.Println("page cache: warning: CONTENT_SECURITY_POLICY uses a {nonce}, so no page will be cached")
+	}
+	if c.config.csrf.HeaderMode {
+		c.ErrorLog.Println("page cache: warning: CSRF_MODE=header sets a cookie on every response, so pages behind NoSurf will not be cached")
+	}
+	if opt.TTL <= 0 {
+		opt.TTL = DefaultPageCacheTTL
+	}
+	if opt.MaxBody <= 0 {
+		opt.MaxBody = DefaultPageCacheMaxBody
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// a page carrying a Content-Security-Policy nonce must not be
+			// served twice
+			if c.Cache == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) ||
+				(opt.Skip != nil && opt.Skip(r)) || c.authenticated(r) || CSPNonce(r) != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			requestCC := parseCacheControl(r.Header.Get("Cache-Control"))
+			if _, ok := requestCC["no-store"]; ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := pageCacheKey(r, opt.Vary)
+
+			_, noCache := requestCC["no-cache"]
+			if !noCache {
+				var page cachedPage
+				if err := c.Cache.Scan(key, &page); err == nil {
+					page.write(w, r)
+					return
+				}
+			}
+
+			// headers set by outer middleware, such as CORS, belong to this
+			// request and are not stored with the page
+			outer := w.Header().Clone()
+
+			body := &limitedBuffer{limit: opt.MaxBody}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(body)
+			ww.Header().Set("X-Cache", "MISS")
+
+			next.ServeHTTP(ww, r)
+
+			ttl, ok := pageCacheTTL(ww.Status(), ww.Header(), opt.TTL)
+			if !ok || body.overflow {
+				return
+			}
+
+			page := cachedPage{
+				Status: ww.Status(),
+				Header: handlerHeaders(outer, ww.Header()),
+				Body:   body.Bytes(),
+				Stored: time.Now().Unix(),
+			}
+			page.Header.Del("X-Cache")
+
+			tags := []string{pageCachePrefix + r.URL.Path}
+			if opt.Tags != nil {
+				for _, tag := range opt.Tags(r) {
+					tags = append(tags, pageCachePrefix+"tag:"+tag)
+				}
+			}
+
+			if err := c.Cache.SetWithTags(key, page, tags, ttl); err != nil {
+				c.ErrorLog.Println("page cache:", err)
+			}
+		})
+	}
+}
+
+// PurgePage removes every cached variant of the pages at paths, whatever
+// their query string or vary headers
+func (c *Celeritas) PurgePage(paths ...string) error {
+	if c.Cache == nil {
+		return nil
+	}
+
+	tags := make([]string, len(paths))
+	for i, path := range paths {
+		tags[i] = pageCachePrefix + path
+	}
+	return c.Cache.FlushTags(tags...)
+}
+
+// PurgePageTags removes every cached page stored with any of tags by
+// PageCacheOptions.Tags
+func (c *Celeritas) PurgePageTags(tags ...string) error {
+	if c.Cache == nil {
+		return nil
+	}
+
+	prefixed := make([]string, len(tags))
+	for i, tag := range tags {
+		prefixed[i] = pageCachePrefix + "tag:" + tag
+	}
+	return c.Cache.FlushTags(prefixed...)
+}
+
+// authenticated reports whether r belongs to a logged in user or carries
+// credentials. Requests whose session was not loaded are treated as
+// authenticated, so that they are never cached.
+func (c *Celeritas) authenticated(r *http.Request) (ok bool) {
+	if r.Header.Get("Authorization") != "" {
+		return true
+	}
+	if c.Session == nil {
+		return false
+	}
+
+	defer func() {
+		if recover() != nil {
+			ok = true
+		}
+	}()
+	return c.Session.Exists(r.Context(), "userID")
+}
+
+// handlerHeaders returns the headers of the response which the handler set
+// or changed, leaving out those already set before it ran
+func handlerHeaders(before, after http.Header) http.Header {
+	header := make(http.Header)
+	for key, values := range after {
+		if !slices.Equal(before[key], values) {
+			header[key] = slices.Clone(values)
+		}
+	}
+	return header
+}
+
+// write sends a cached page to the client
+func (p *cachedPage) write(w http.ResponseWriter, r *http.Request) {
+	for key, values := range p.Header {
+		w.Header()[key] = values
+	}
+
+	age := time.Now().Unix() - p.Stored
+	if age < 0 {
+		age = 0
+	}
+	w.Header().Set("Age", strconv.FormatInt(age, 10))
+	w.Header().Set("X-Cache", "HIT")
+
+	w.WriteHeader(p.Status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(p.Body)
+	}
+}
+
+// pageCacheKey identifies a response by method, path, query and the values
+// of the vary headers. The query is normalised so that the order of its
+// parameters does not matter.
+func pageCacheKey(r *http.Request, vary []string) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + "\n" + r.URL.Query().Encode()))
+	for _, name := range vary {
+		h.Write([]byte("\n" + textproto.CanonicalMIMEHeaderKey(name) + ":" +
+			strings.Join(r.Header.Values(name), ",")))
+	}
+
+	return pageCachePrefix + r.URL.Path + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// pageCacheTTL returns how many seconds a response may be cached for, and
+// false if it must not be cached at all
+func pageCacheTTL(status int, header http.Header, ttl int) (int, bool) {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMovedPermanently, http.StatusPermanentRedirect,
+		http.StatusNotFound, http.StatusGone:
+	default:
+		return 0, false
+	}
+
+	if len(header.Values("Set-Cookie")) > 0 || header.Get("Vary") == "*" {
+		return 0, false
+	}
+
+	cc := parseCacheControl(header.Get("Cache-Control"))
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[directive]; ok {
+			return 0, false
+		}
+	}
+
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := cc[directive]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			return seconds, true
+		}
+	}
+
+	return ttl, true
+}
+
+// parseCacheControl splits a Cache-Control header into its directives
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return directives
+}
+
+// limitedBuffer keeps what is written to it until it grows past limit
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if !b.overflow {
+		if b.Len()+len(p) > b.limit {
+			b.overflow = true
+			b.Reset()
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
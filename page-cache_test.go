@@ -0,0 +1,107 @@
+package celeritas
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+func TestPageCache(t *testing.T) {
+	c := newTestCeleritas()
+	c.Session = scs.New()
+
+	var served int
+	page := func(w http.ResponseWriter, r *http.Request) {
+		served++
+		if r.URL.Query().Has("cookie") {
+			http.SetCookie(w, &http.Cookie{Name: "seen", Value: "1"})
+		}
+		_, _ = w.Write([]byte("page " + strconv.Itoa(served)))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		c.Session.Put(r.Context(), "userID", 1)
+	})
+	mux.Handle("/", c.PageCache()(http.HandlerFunc(page)))
+	handler := c.Session.LoadAndSave(mux)
+
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	if rr := get("/", nil); rr.Header().Get("X-Cache") != "MISS" || rr.Body.String() != "page 1" {
+		t.Errorf("expected a miss serving page 1, got %q %q", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+	if rr := get("/", nil); rr.Header().Get("X-Cache") != "HIT" || rr.Body.String() != "page 1" {
+		t.Errorf("expected a hit serving page 1, got %q %q", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+
+	if rr := get("/", http.Header{"Authorization": {"Bearer token"}}); rr.Header().Get("X-Cache") != "" || rr.Body.String() != "page 2" {
+		t.Errorf("expected a request with credentials to bypass the cache, got %q %q", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+
+	session := get("/login", nil).Result().Cookies()[0]
+	cookie := http.Header{"Cookie": {session.Name + "=" + session.Value}}
+	if rr := get("/", cookie); rr.Header().Get("X-Cache") != "" || rr.Body.String() != "page 3" {
+		t.Errorf("expected a logged in user to bypass the cache, got %q %q", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+
+	for i := 0; i < 2; i++ {
+		if rr := get("/?cookie", nil); rr.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("expected a response setting a cookie not to be cached, got %q", rr.Header().Get("X-Cache"))
+		}
+	}
+	if served != 5 {
+		t.Errorf("expected the handler to serve 5 requests, got %d", served)
+	}
+}
+
+func TestPageCache_CORS(t *testing.T) {
+	c := newTestCeleritas()
+	c.config.cors = CORSOptions{AllowedOrigins: []string{"https://a.test", "https://b.test"}}
+
+	handler := c.CORS(c.PageCache()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Language", "en")
+		_, _ = w.Write([]byte("page"))
+	})))
+
+	for _, origin := range []string{"https://a.test", "https://b.test"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != origin {
+			t.Errorf("%s: expected Access-Control-Allow-Origin %q, got %q", origin, origin, got)
+		}
+		if got := rr.Header().Get("Content-Language"); got != "en" {
+			t.Errorf("%s: expected the page's own headers, got Content-Language %q", origin, got)
+		}
+	}
+}
+
+func TestPageCache_NonceWarning(t *testing.T) {
+	var logged bytes.Buffer
+
+	c := newTestCeleritas()
+	c.ErrorLog = log.New(&logged, "", 0)
+	c.config.securityHeaders.CSP = "script-src 'nonce-{nonce}'"
+	c.PageCache()
+
+	if !strings.Contains(logged.String(), "{nonce}") {
+		t.Errorf("expected a warning that nothing will be cached, got %q", logged.String())
+	}
+}
@@ -0,0 +1,181 @@
+package celeritas
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/s-petr/celeritas/filesystems"
+	"github.com/s-petr/celeritas/urlsigner"
+)
+
+// signedFilesPath is the route used to serve presigned URLs for file systems
+// which cannot generate them themselves
+const signedFilesPath = "/_files"
+
+// Presigner returns a filesystems.Presigner for the named file system. The
+// s3 and minio drivers generate presigned URLs natively; every other driver
+// falls back to a framework route protected by a urlsigner.Signer.
+func (c *Celeritas) Presigner(name string) (filesystems.Presigner, error) {
+	fs, err := c.FileSystem(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if p, ok := fs.(filesystems.Presigner); ok {
+		return p, nil
+	}
+
+	return &routePresigner{c: c, name: name}, nil
+}
+
+// FileSystem returns the configured file system (disk) with the given name,
+// e.g. s3 or avatars; names are case insensitive
+func (c *Celeritas) FileSystem(name string) (filesystems.FS, error) {
+	if c.FileSystems == nil {
+		return nil, fmt.Errorf("file system %s is not configured", name)
+	}
+	return c.FileSystems.Disk(name)
+}
+
+// DefaultFileSystem returns the disk named by FILESYSTEM_DEFAULT
+func (c *Celeritas) DefaultFileSystem() (filesystems.FS, error) {
+	if c.FileSystems == nil {
+		return nil, filesystems.ErrNoDefault
+	}
+	return c.FileSystems.Default()
+}
+
+// routePresigner presigns URLs pointing at the framework's signed file route
+type routePresigner struct {
+	c    *Celeritas
+	name string
+}
+
+func (p *routePresigner) PresignGet(item string, expires time.Duration) (string, error) {
+	return p.c.signFileURL(http.MethodGet, p.name, item, expires)
+}
+
+func (p *routePresigner) PresignPut(item string, expires time.Duration) (string, error) {
+	return p.c.signFileURL(http.MethodPut, p.name, item, expires)
+}
+
+func (c *Celeritas) fileSigner() (*urlsigner.Signer, error) {
+	if c.EncryptionKey == "" {
+		return nil, errors.New("an encryption key (KEY) is required to sign file URLs")
+	}
+	return &urlsigner.Signer{Secret: []byte(c.EncryptionKey)}, nil
+}
+
+func (c *Celeritas) signFileURL(method, name, item string, expires time.Duration) (string, error) {
+	signer, err := c.fileSigner()
+	if err != nil {
+		return "", err
+	}
+
+	u := url.URL{Path: path.Join(signedFilesPath, name, item)}
+	query := url.Values{}
+	query.Set("method", method)
+	query.Set("expires", strconv.FormatInt(time.Now().Add(expires).Unix(), 10))
+
+	return signer.GenerateTokenFromString(fmt.Sprintf("%s%s?%s",
+		c.Server.URL, u.EscapedPath(), query.Encode())), nil
+}
+
+// verifySignedFileRequest checks the signature and expiry of a request to
+// the signed file route and returns the file system and item it refers to
+func (c *Celeritas) verifySignedFileRequest(r *http.Request) (filesystems.FS, string, bool) {
+	signer, err := c.fileSigner()
+	if err != nil {
+		return nil, "", false
+	}
+
+	if !signer.VerifyToken(c.Server.URL + r.RequestURI) {
+		return nil, "", false
+	}
+
+	if r.URL.Query().Get("method") != r.Method {
+		return nil, "", false
+	}
+
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return nil, "", false
+	}
+
+	fs, err := c.FileSystem(chi.URLParam(r, "fs"))
+	if err != nil {
+		return nil, "", false
+	}
+
+	item := strings.TrimPrefix(path.Clean("/"+chi.URLParam(r, "*")), "/")
+	return fs, item, true
+}
+
+func (c *Celeritas) serveSignedFile(w http.ResponseWriter, r *http.Request) {
+	fs, item, ok := c.verifySignedFileRequest(r)
+	if !ok {
+		c.ErrorStatus(w, http.StatusForbidden)
+		return
+	}
+
+	reader, err := fs.Open(item)
+	if err != nil {
+		if errors.Is(err, filesystems.ErrNotExist) {
+			c.ErrorNotFound404(w, r)
+			return
+		}
+		c.ErrorLog.Println(err)
+		c.ErrorIntServErr500(w, r)
+		return
+	}
+	defer reader.Close()
+
+	if contentType := mime.TypeByExtension(path.Ext(item)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	if _, err := io.Copy(w, reader); err != nil {
+		c.ErrorLog.Println(err)
+	}
+}
+
+func (c *Celeritas) receiveSignedFile(w http.ResponseWriter, r *http.Request) {
+	fs, item, ok := c.verifySignedFileRequest(r)
+	if !ok {
+		c.ErrorStatus(w, http.StatusForbidden)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, c.config.upload.maxUploadSize)
+
+	writer, err := fs.Create(item)
+	if err != nil {
+		c.ErrorLog.Println(err)
+		c.ErrorIntServErr500(w, r)
+		return
+	}
+
+	if _, err := io.Copy(writer, body); err != nil {
+		_ = filesystems.Abort(writer, err)
+		c.ErrorLog.Println(err)
+		c.ErrorStatus(w, http.StatusBadRequest)
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		c.ErrorLog.Println(err)
+		c.ErrorIntServErr500(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
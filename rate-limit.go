@@ -0,0 +1,222 @@
+package celeritas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s-petr/celeritas/cache"
+)
+
+// Rate limiting algorithms
+const (
+	// FixedWindow allows Limit requests in each Window, counted from the
+	// start of the window
+	FixedWindow = "fixed-window"
+	// TokenBucket allows bursts of up to Burst requests, refilled at Limit
+	// requests per Window
+	TokenBucket = "token-bucket"
+)
+
+// RateLimitOptions configure the RateLimit middleware
+type RateLimitOptions struct {
+	// Name keeps the counts of different limits apart
+	Name string
+	// Limit is how many requests are allowed per Window
+	Limit  int
+	Window time.Duration
+	// Algorithm is FixedWindow (the default) or TokenBucket
+	Algorithm string
+	// Burst is the size of the bucket for TokenBucket, Limit if 0
+	Burst int
+	// KeyBy returns who a request is counted against, RateLimitByIP if nil
+	KeyBy func(r *http.Request) string
+	// OnLimited, if set, handles requests over the limit instead of a plain
+	// 429 Too Many Requests
+	OnLimited http.Handler
+}
+
+// rateLimitState is what the headers of a rate limited response report
+type rateLimitState struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// RateLimit returns middleware which limits how often requests are made,
+// counting them in c.Cache so that the limit is shared by every instance.
+// Responses carry RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers, and requests over the limit are answered with
+// 429 Too Many Requests and a Retry-After header. If the cache cannot be
+// reached, requests are let through.
+//
+//	mux.With(c.RateLimit(celeritas.RateLimitOptions{Name: "login", Limit: 5, Window: time.Minute})).Post("/users/login", h.PostUserLogin)
+func (c *Celeritas) RateLimit(opts RateLimitOptions) func(http.Handler) http.Handler {
+	if opts.Limit < 1 {
+		opts.Limit = 1
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.KeyBy == nil {
+		opts.KeyBy = RateLimitByIP
+	}
+
+	policy := fmt.Sprintf("%d;w=%d", opts.Limit, int(opts.Window.Seconds()))
+	if opts.Algorithm == TokenBucket && opts.Burst > 0 {
+		policy += fmt.Sprintf(";burst=%d", opts.Burst)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c.Cache == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := "ratelimit:" + opts.Name + ":" + opts.KeyBy(r)
+
+			var state rateLimitState
+			var err error
+			if opts.Algorithm == TokenBucket {
+				state, err = c.takeToken(key, opts)
+			} else {
+				state, err = c.countInWindow(key, opts)
+			}
+			if err != nil {
+				c.ErrorLog.Println("rate limit:", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			limit := opts.Limit
+			if opts.Algorithm == TokenBucket && opts.Burst > 0 {
+				limit = opts.Burst
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(state.remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(cache.CeilSeconds(state.reset)))
+			w.Header().Set("RateLimit-Policy", policy)
+
+			if !state.allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(cache.CeilSeconds(state.retryAfter)))
+				if opts.OnLimited != nil {
+					opts.OnLimited.ServeHTTP(w, r)
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// countInWindow counts a request against key in the current fixed window
+func (c *Celeritas) countInWindow(key string, opts RateLimitOptions) (rateLimitState, error) {
+	now := time.Now()
+	window := now.Truncate(opts.Window)
+	reset := window.Add(opts.Window).Sub(now)
+	key = fmt.Sprintf("%s:%d", key, window.Unix())
+
+	ttl := cache.CeilSeconds(reset) + 1
+
+	var n int64
+	var err error
+	if counter, ok := c.Cache.(cache.ExpiringCounter); ok {
+		n, err = counter.IncrementExpiring(key, 1, ttl)
+	} else {
+		n, err = c.Cache.Increment(key, 1)
+		if err == nil && n == 1 {
+			// a counter left without an expiry would limit the client
+			// for good
+			if err = c.Cache.Touch(key, ttl); err != nil {
+				_ = c.Cache.Forget(key)
+			}
+		}
+	}
+	if err != nil {
+		return rateLimitState{}, err
+	}
+
+	state := rateLimitState{
+		allowed:   n <= int64(opts.Limit),
+		remaining: opts.Limit - int(n),
+		reset:     reset,
+	}
+	if state.remaining < 0 {
+		state.remaining = 0
+	}
+	if !state.allowed {
+		state.retryAfter = reset
+	}
+	return state, nil
+}
+
+// takeToken takes a token for key from a bucket in the cache, if the cache
+// supports token buckets
+func (c *Celeritas) takeToken(key string, opts RateLimitOptions) (rateLimitState, error) {
+	buckets, ok := c.Cache.(cache.TokenBucket)
+	if !ok {
+		return rateLimitState{}, fmt.Errorf("%T does not support token buckets", c.Cache)
+	}
+
+	state, err := buckets.TakeToken(key, cache.Bucket{Rate: opts.Limit, Period: opts.Window, Burst: opts.Burst})
+	if err != nil {
+		return rateLimitState{}, err
+	}
+
+	return rateLimitState{
+		allowed:    state.Allowed,
+		remaining:  state.Remaining,
+		reset:      state.Reset,
+		retryAfter: state.RetryAfter,
+	}, nil
+}
+
+// RateLimitByIP counts requests against the client's IP address. Use the
+// RealIP middleware before RateLimit when behind a proxy.
+func RateLimitByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// RateLimitByToken counts requests against the bearer token they carry,
+// or the client's IP address if they have none
+func RateLimitByToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return RateLimitByIP(r)
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:])
+}
+
+// RateLimitByUser counts requests against the logged in user, or the
+// client's IP address for guests. It must be used after SessionLoad.
+func (c *Celeritas) RateLimitByUser(r *http.Request) (key string) {
+	key = RateLimitByIP(r)
+	if c.Session == nil {
+		return key
+	}
+
+	// the session is missing if SessionLoad has not run
+	defer func() { _ = recover() }()
+
+	if userID := c.Session.Get(r.Context(), "userID"); userID != nil {
+		return fmt.Sprintf("user:%v", userID)
+	}
+	return key
+}
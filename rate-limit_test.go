@@ -0,0 +1,84 @@
+package celeritas
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/s-petr/celeritas/cache"
+)
+
+func TestRateLimit(t *testing.T) {
+	c := newTestCeleritas()
+	handler := c.RateLimit(RateLimitOptions{Name: "test", Limit: 2, Window: time.Minute})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = ip + ":1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		rr := request("10.0.0.1")
+		if rr.Code != http.StatusOK {
+			t.Errorf("request %d: expected 200, got %d", i+1, rr.Code)
+		}
+		if got := rr.Header().Get("RateLimit-Remaining"); got != remaining {
+			t.Errorf("request %d: expected %s remaining, got %q", i+1, remaining, got)
+		}
+		if got := rr.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: expected a limit of 2, got %q", i+1, got)
+		}
+		if got := rr.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+			t.Errorf("request %d: expected the policy 2;w=60, got %q", i+1, got)
+		}
+	}
+
+	rr := request("10.0.0.1")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 over the limit, got %d", rr.Code)
+	}
+	if retry := rr.Header().Get("Retry-After"); retry == "" || retry == "0" {
+		t.Errorf("expected a Retry-After header, got %q", retry)
+	}
+
+	if rr := request("10.0.0.2"); rr.Code != http.StatusOK {
+		t.Errorf("expected another client to have its own limit, got %d", rr.Code)
+	}
+}
+
+// touchFailingCache cannot set expiries, nor increment with one
+type touchFailingCache struct {
+	cache.Cache
+}
+
+func (touchFailingCache) Touch(string, int) error {
+	return errors.New("connection reset")
+}
+
+func TestRateLimit_TouchFails(t *testing.T) {
+	memoryCache := cache.NewMemoryCache(0)
+	c := newTestCeleritas()
+	c.Cache = touchFailingCache{memoryCache}
+
+	handler := c.RateLimit(RateLimitOptions{Name: "touch", Limit: 1, Window: time.Minute})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("request %d: expected a request to be let through when the cache fails, got %d", i+1, rr.Code)
+		}
+	}
+
+	// no counter is left behind without an expiry
+	if n := memoryCache.Len(); n != 0 {
+		t.Errorf("expected no counters to be kept, got %d", n)
+	}
+}
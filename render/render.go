@@ -1,6 +1,7 @@
 package render
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"html/template"
@@ -21,6 +22,9 @@ type Render struct {
 	ServerName string
 	JetViews   *jet.Set
 	Session    *scs.SessionManager
+	// OnPage, if set, is called with every page about to be rendered, so
+	// that tests can see which template a handler chose and with what data
+	OnPage func(r *http.Request, templateName string, td *TemplateData)
 }
 
 type TemplateData struct {
@@ -35,6 +39,21 @@ type TemplateData struct {
 	Secure          bool
 	Error           string
 	Flash           string
+	CSPNonce        string
+}
+
+type nonceKey struct{}
+
+// WithNonce returns a copy of ctx carrying the Content-Security-Policy nonce
+// of a request, for pages rendered in it to use
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceKey{}, nonce)
+}
+
+// Nonce returns the Content-Security-Policy nonce of r, or "" if it has none
+func Nonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(nonceKey{}).(string)
+	return nonce
 }
 
 func (c *Render) defaultData(td *TemplateData, r *http.Request) *TemplateData {
@@ -42,6 +61,11 @@ func (c *Render) defaultData(td *TemplateData, r *http.Request) *TemplateData {
 	td.ServerName = c.ServerName
 	td.CSRFToken = nosurf.Token(r)
 	td.Port = c.Port
+	td.CSPNonce = Nonce(r)
+
+	if c.Session == nil {
+		return td
+	}
 
 	if c.Session.Exists(r.Context(), "userID") {
 		td.IsAuthenticated = true
@@ -75,6 +99,11 @@ func (c *Render) GoPage(w http.ResponseWriter, r *http.Request,
 	if data != nil {
 		td = data.(*TemplateData)
 	}
+	td.CSPNonce = Nonce(r)
+
+	if c.OnPage != nil {
+		c.OnPage(r, templateName, td)
+	}
 
 	err = tmpl.Execute(w, &td)
 	if err != nil {
@@ -107,6 +136,10 @@ func (c *Render) JetPage(w http.ResponseWriter, r *http.Request,
 		return err
 	}
 
+	if c.OnPage != nil {
+		c.OnPage(r, templateName, td)
+	}
+
 	if err = t.Execute(w, vars, td); err != nil {
 		log.Println(err)
 		return err
@@ -27,6 +27,7 @@ func TestRender_Page(t *testing.T) {
 		if err != nil {
 			t.Error(err)
 		}
+		r = r.WithContext(getCtx(r))
 
 		w := httptest.NewRecorder()
 
@@ -88,3 +89,53 @@ func TestRender_JetPage(t *testing.T) {
 		t.Error("render non-existent jet template - expected an error, received none", err)
 	}
 }
+
+func TestRender_Nonce(t *testing.T) {
+	r, err := http.NewRequest("GET", "/test", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if nonce := Nonce(r); nonce != "" {
+		t.Errorf("expected no nonce, got %q", nonce)
+	}
+
+	r = r.WithContext(WithNonce(getCtx(r), "abc123"))
+
+	td := testRenderer.defaultData(&TemplateData{}, r)
+	if td.CSPNonce != "abc123" {
+		t.Errorf("expected the nonce in the template data, got %q", td.CSPNonce)
+	}
+}
+
+func TestRender_OnPage(t *testing.T) {
+	r, err := http.NewRequest("GET", "/test", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	r = r.WithContext(getCtx(r))
+
+	var rendered string
+	testRenderer.OnPage = func(_ *http.Request, templateName string, _ *TemplateData) {
+		rendered = templateName
+	}
+	defer func() { testRenderer.OnPage = nil }()
+
+	testRenderer.Renderer = "jet"
+	testRenderer.RootPath = "./testdata"
+
+	if err := testRenderer.Page(httptest.NewRecorder(), r, "home", nil, nil); err != nil {
+		t.Error(err)
+	}
+	if rendered != "home" {
+		t.Errorf("expected OnPage to see home, got %q", rendered)
+	}
+
+	rendered = ""
+	if err := testRenderer.Page(httptest.NewRecorder(), r, "no-such-page", nil, nil); err == nil {
+		t.Error("expected an error rendering a missing template")
+	}
+	if rendered != "" {
+		t.Errorf("expected OnPage not to be called for a missing template, got %q", rendered)
+	}
+}
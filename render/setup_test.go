@@ -1,10 +1,13 @@
 package render
 
 import (
+	"context"
+	"net/http"
 	"os"
 	"testing"
 
 	"github.com/CloudyKit/jet/v6"
+	"github.com/alexedwards/scs/v2"
 )
 
 var views = jet.NewSet(
@@ -12,10 +15,21 @@ var views = jet.NewSet(
 	jet.InDevelopmentMode(),
 )
 
+var testSession = scs.New()
+
 var testRenderer = Render{
 	Renderer: "",
 	RootPath: "",
 	JetViews: views,
+	Session:  testSession,
+}
+
+func getCtx(r *http.Request) context.Context {
+	ctx, err := testSession.Load(r.Context(), r.Header.Get("X-Session"))
+	if err != nil {
+		panic(err)
+	}
+	return ctx
 }
 
 func TestMain(m *testing.M) {
@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"path"
 	"path/filepath"
+
+	"github.com/s-petr/celeritas/filesystems"
 )
 
 func (c *Celeritas) ReadJSON(w http.ResponseWriter,
@@ -75,6 +78,32 @@ func (c *Celeritas) DownloadFile(w http.ResponseWriter,
 	http.ServeFile(w, r, fileToServe)
 }
 
+// DownloadFileFromFS streams item from fs to the client as an attachment
+// without writing it to the local disk first
+func (c *Celeritas) DownloadFileFromFS(w http.ResponseWriter,
+	r *http.Request, fs filesystems.FS, item, fileName string) error {
+	reader, err := fs.Open(item)
+	if err != nil {
+		if errors.Is(err, filesystems.ErrNotExist) {
+			c.ErrorNotFound404(w, r)
+		} else {
+			c.ErrorIntServErr500(w, r)
+		}
+		return err
+	}
+	defer reader.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(fileName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	_, err = io.Copy(w, reader)
+	return err
+}
+
 func (c *Celeritas) ErrorNotFound404(w http.ResponseWriter,
 	r *http.Request) {
 	c.ErrorStatus(w, http.StatusNotFound)
@@ -13,6 +13,8 @@ func (c *Celeritas) routes() http.Handler {
 	mux.Use(middleware.RequestID)
 	mux.Use(middleware.RealIP)
 	mux.Use(middleware.Recoverer)
+	mux.Use(c.SecureHeaders)
+	mux.Use(c.CORS)
 	mux.Use(c.NoSurf)
 	mux.Use(c.SessionLoad)
 	mux.Use(c.CheckForMaintenanceMode)
@@ -21,5 +23,8 @@ func (c *Celeritas) routes() http.Handler {
 		mux.Use(middleware.Logger)
 	}
 
+	mux.Get(signedFilesPath+"/{fs}/*", c.serveSignedFile)
+	mux.Put(signedFilesPath+"/{fs}/*", c.receiveSignedFile)
+
 	return mux
 }
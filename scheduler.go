@@ -0,0 +1,346 @@
+package celeritas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/s-petr/celeritas/cache"
+)
+
+// Scheduler runs cron jobs. It is started and stopped with the server, and
+// embeds *cron.Cron so that jobs may still be added with AddFunc, but only
+// jobs added with Register are named, timed out, kept from overlapping and
+// have their runs recorded.
+type Scheduler struct {
+	*cron.Cron
+	// Locker provides the locks for jobs registered with JobOptions.Lock
+	Locker cache.Locker
+	// History, if set, is where the last run of each job is recorded, so
+	// that it is shared by every instance
+	History  cache.Cache
+	ErrorLog *log.Logger
+
+	mu      sync.Mutex
+	jobs    map[string]*scheduledJob
+	running sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// JobOptions configure a job registered with Scheduler.Register
+type JobOptions struct {
+	// Timeout cancels the context passed to the job after this long; 0 means
+	// no timeout
+	Timeout time.Duration
+	// Lock, if set, runs the job only on the instance holding a lock on it,
+	// held for this long at a time. The job's context carries the lock's
+	// fence, see LockFence, and is cancelled if the lock is lost. See
+	// Celeritas.OnLockHolder.
+	Lock time.Duration
+}
+
+// JobRun records one run of a job
+type JobRun struct {
+	Started  time.Time
+	Duration time.Duration
+	Error    string
+}
+
+// JobStatus describes a registered job
+type JobStatus struct {
+	Name    string
+	Spec    string
+	Timeout time.Duration
+	Running bool
+	Next    time.Time
+	LastRun *JobRun
+}
+
+type scheduledJob struct {
+	name    string
+	spec    string
+	fn      func(ctx context.Context) error
+	opts    JobOptions
+	id      cron.EntryID
+	running bool
+	lastRun *JobRun
+}
+
+const jobHistoryPrefix = "scheduler:history:"
+
+// NewScheduler returns a scheduler which logs errors to errorLog
+func NewScheduler(errorLog *log.Logger) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		Cron:     cron.New(),
+		ErrorLog: errorLog,
+		jobs:     make(map[string]*scheduledJob),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Register schedules fn to run as the job called name whenever spec is
+// due. A run is skipped if the previous one has not finished.
+func (s *Scheduler) Register(name, spec string, fn func(ctx context.Context) error, opts ...JobOptions) error {
+	job := &scheduledJob{name: name, spec: spec, fn: fn}
+	if len(opts) > 0 {
+		job.opts = opts[0]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("scheduler: job %s is already registered", name)
+	}
+
+	id, err := s.AddFunc(spec, func() { s.run(job) })
+	if err != nil {
+		return fmt.Errorf("scheduler: job %s: %w", name, err)
+	}
+
+	job.id = id
+	s.jobs[name] = job
+	return nil
+}
+
+// RunNow runs the job called name straight away, unless it is already
+// running
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("scheduler: no job called %s", name)
+	}
+
+	go s.run(job)
+	return nil
+}
+
+// Jobs lists the registered jobs in name order
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	jobs := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		status := JobStatus{
+			Name:    job.name,
+			Spec:    job.spec,
+			Timeout: job.opts.Timeout,
+			Running: job.running,
+			Next:    s.Entry(job.id).Next,
+			LastRun: job.lastRun,
+		}
+
+		jobs = append(jobs, status)
+	}
+	s.mu.Unlock()
+
+	if s.History != nil {
+		for i := range jobs {
+			var run JobRun
+			if err := s.History.Scan(jobHistoryPrefix+jobs[i].Name, &run); err == nil {
+				jobs[i].LastRun = &run
+			}
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// Shutdown stops scheduling jobs, cancels the context of any which are
+// running and waits up to timeout for them to return
+func (s *Scheduler) Shutdown(timeout time.Duration) error {
+	s.Stop()
+
+	s.mu.Lock()
+	s.cancel()
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("scheduler: timed out waiting for jobs to finish")
+	}
+}
+
+// run runs job unless it is already running, and records the result
+func (s *Scheduler) run(job *scheduledJob) {
+	s.mu.Lock()
+	if job.running || s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return
+	}
+	job.running = true
+	s.running.Add(1)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		job.running = false
+		s.mu.Unlock()
+		s.running.Done()
+	}()
+
+	if job.opts.Lock > 0 && s.Locker != nil {
+		runOnLockHolder(s.ctx, s.Locker, s.ErrorLog, job.name, job.opts.Lock,
+			func(ctx context.Context, _ int64) { s.runJob(ctx, job) })
+		return
+	}
+
+	s.runJob(s.ctx, job)
+}
+
+func (s *Scheduler) runJob(ctx context.Context, job *scheduledJob) {
+	if job.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.opts.Timeout)
+		defer cancel()
+	}
+
+	run := &JobRun{Started: time.Now()}
+	err := runJobFunc(ctx, job.fn)
+	run.Duration = time.Since(run.Started)
+
+	if err == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", job.opts.Timeout)
+	}
+	if err == nil && errors.Is(context.Cause(ctx), cache.ErrLockLost) {
+		err = fmt.Errorf("lost the lock while running: %w", context.Cause(ctx))
+	}
+
+	if err != nil {
+		run.Error = err.Error()
+		s.ErrorLog.Printf("scheduler: job %s failed: %s", job.name, err)
+	}
+
+	s.mu.Lock()
+	job.lastRun = run
+	s.mu.Unlock()
+
+	if s.History != nil {
+		if err := s.History.Set(jobHistoryPrefix+job.name, *run); err != nil {
+			s.ErrorLog.Printf("scheduler: could not record run of %s: %s", job.name, err)
+		}
+	}
+}
+
+// runJobFunc calls fn, turning a panic into an error
+func runJobFunc(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// OnLockHolder returns a cron.JobWrapper which runs a job only on the
+// instance that acquires the lock called name, so that a job scheduled on
+// every instance runs once each time it is due. The lock is renewed while
+// the job runs and then left to expire after ttl, so instances whose clocks
+// run slightly behind do not run the job again; ttl should be shorter than
+// the interval between runs.
+//
+//	c.Scheduler.AddJob("@hourly", cron.NewChain(c.OnLockHolder("report", time.Minute)).Then(job))
+func (c *Celeritas) OnLockHolder(name string, ttl time.Duration) cron.JobWrapper {
+	return func(job cron.Job) cron.Job {
+		return cron.FuncJob(func() {
+			c.OnLockHolderFunc(name, ttl, func(context.Context, int64) { job.Run() }).Run()
+		})
+	}
+}
+
+// OnLockHolderFunc returns a cron.Job which calls fn only on the instance
+// that acquires the lock called name, like OnLockHolder. fn is given the
+// lock's fence, to pass on to the stores it writes to so that they can
+// reject writes from an instance which has since lost the lock, and a
+// context which is cancelled if the lock is lost while fn runs. Without a
+// Locker fn always runs, with a fence of 0.
+//
+//	c.Scheduler.AddJob("@hourly", c.OnLockHolderFunc("report", time.Minute,
+//		func(ctx context.Context, fence int64) { report.Send(ctx, fence) }))
+func (c *Celeritas) OnLockHolderFunc(name string, ttl time.Duration, fn func(ctx context.Context, fence int64)) cron.Job {
+	return cron.FuncJob(func() {
+		if c.Locker == nil {
+			fn(context.Background(), 0)
+			return
+		}
+
+		runOnLockHolder(context.Background(), c.Locker, c.ErrorLog, name, ttl, fn)
+	})
+}
+
+type lockFenceKey struct{}
+
+// LockFence returns the fence of the lock held while a job scheduled with
+// JobOptions.Lock runs, from the context passed to the job
+func LockFence(ctx context.Context) (int64, bool) {
+	fence, ok := ctx.Value(lockFenceKey{}).(int64)
+	return fence, ok
+}
+
+// runOnLockHolder calls fn if the lock called name can be acquired, and
+// keeps the lock while fn runs. The context passed to fn carries the lock's
+// fence and is cancelled, with the renewal error as its cause, if the lock
+// is lost.
+func runOnLockHolder(ctx context.Context, locker cache.Locker, errorLog *log.Logger, name string, ttl time.Duration, fn func(ctx context.Context, fence int64)) {
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+
+	lock, err := locker.AcquireLock("scheduler:"+name, ttl)
+	if errors.Is(err, cache.ErrLockHeld) {
+		return
+	}
+	if err != nil {
+		errorLog.Printf("scheduler: could not lock %s: %s", name, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancelCause(context.WithValue(ctx, lockFenceKey{}, lock.Fence))
+	defer cancel(nil)
+	go renewLock(ctx, lock, ttl, errorLog, cancel)
+
+	fn(ctx, lock.Fence)
+}
+
+// renewLock keeps lock for ttl at a time until ctx is done, cancelling ctx
+// if the lock cannot be renewed
+func renewLock(ctx context.Context, lock *cache.Lock, ttl time.Duration, errorLog *log.Logger, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := lock.Renew(ttl); err != nil {
+				errorLog.Printf("scheduler: lost lock %s: %s", lock.Name, err)
+				if !errors.Is(err, cache.ErrLockLost) {
+					err = fmt.Errorf("%w: %w", cache.ErrLockLost, err)
+				}
+				cancel(err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package celeritas
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s-petr/celeritas/cache"
+)
+
+// recordingLocker keeps the locks it hands out, so that a test can release
+// one from under its holder
+type recordingLocker struct {
+	*cache.MemoryCache
+	mu   sync.Mutex
+	lock *cache.Lock
+}
+
+func (l *recordingLocker) AcquireLock(name string, ttl time.Duration) (*cache.Lock, error) {
+	lock, err := l.MemoryCache.AcquireLock(name, ttl)
+	if err == nil {
+		l.mu.Lock()
+		l.lock = lock
+		l.mu.Unlock()
+	}
+	return lock, err
+}
+
+func (l *recordingLocker) last() *cache.Lock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lock
+}
+
+func TestOnLockHolderFunc(t *testing.T) {
+	c := newTestCeleritas()
+	locker := &recordingLocker{MemoryCache: cache.NewMemoryCache(0)}
+	c.Locker = locker
+
+	var (
+		fence int64
+		cause error
+	)
+	c.OnLockHolderFunc("report", time.Second, func(ctx context.Context, f int64) {
+		fence = f
+		_ = locker.last().Release()
+
+		select {
+		case <-ctx.Done():
+			cause = context.Cause(ctx)
+		case <-time.After(2 * time.Second):
+		}
+	}).Run()
+
+	if fence < 1 {
+		t.Errorf("expected the job to be given the lock's fence, got %d", fence)
+	}
+	if !errors.Is(cause, cache.ErrLockLost) {
+		t.Errorf("expected the job's context to be cancelled with ErrLockLost, got %v", cause)
+	}
+
+	c.Locker = nil
+	ran := false
+	c.OnLockHolderFunc("report", time.Second, func(ctx context.Context, f int64) {
+		ran = f == 0
+	}).Run()
+	if !ran {
+		t.Error("expected the job to run with a fence of 0 without a Locker")
+	}
+}
+
+func TestScheduler_LockFence(t *testing.T) {
+	c := newTestCeleritas()
+
+	s := NewScheduler(c.ErrorLog)
+	s.Locker = c.Locker
+
+	fences := make(chan int64, 1)
+	err := s.Register("report", "@hourly", func(ctx context.Context) error {
+		fence, _ := LockFence(ctx)
+		fences <- fence
+		return nil
+	}, JobOptions{Lock: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RunNow("report"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case fence := <-fences:
+		if fence < 1 {
+			t.Errorf("expected the job's context to carry the lock's fence, got %d", fence)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("the job did not run")
+	}
+}
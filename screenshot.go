@@ -1,7 +1,15 @@
 package celeritas
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/go-rod/rod"
@@ -9,10 +17,16 @@ import (
 	"github.com/go-rod/rod/lib/utils"
 )
 
-func (c *Celeritas) TakeScreenShot(pageURL, testName string, w, h float64) {
-	page := c.FetchPage(pageURL)
+// TakeScreenShot saves a w x h screenshot of pageURL in the screenshots
+// folder, named after testName and the time
+func (c *Celeritas) TakeScreenShot(pageURL, testName string, w, h float64) error {
+	page, err := c.FetchPage(pageURL)
+	if err != nil {
+		return err
+	}
+	defer page.Close()
 
-	img, _ := page.Screenshot(true, &proto.PageCaptureScreenshot{
+	img, err := page.Screenshot(true, &proto.PageCaptureScreenshot{
 		Format: proto.PageCaptureScreenshotFormatPng,
 		Clip: &proto.PageViewport{
 			X:      0,
@@ -23,20 +37,163 @@ func (c *Celeritas) TakeScreenShot(pageURL, testName string, w, h float64) {
 		},
 		FromSurface: true,
 	})
+	if err != nil {
+		return err
+	}
+
 	fileName := time.Now().Format("2006-01-02-15-04-05.000000")
-	_ = utils.OutputFile(fmt.Sprintf("%s/screenshots/%s-%s.png",
+	return utils.OutputFile(fmt.Sprintf("%s/screenshots/%s-%s.png",
 		c.RootPath, testName, fileName), img)
-
 }
 
-func (c *Celeritas) FetchPage(pageURL string) *rod.Page {
-	return rod.New().
-		MustConnect().
-		MustIgnoreCertErrors(true).
-		MustPage(pageURL).
-		MustWaitLoad()
+// FetchPage opens pageURL in the application's shared Browser. The caller
+// closes the page when done with it.
+//
+// Deprecated: use Browser().Open, whose pages have helpers for forms,
+// waits and screenshots.
+func (c *Celeritas) FetchPage(pageURL string) (*rod.Page, error) {
+	page, err := c.Browser().Open(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	return page.Page, nil
 }
 
 func (c *Celeritas) SelectElementByID(page *rod.Page, id string) *rod.Element {
 	return page.MustElementByJS(fmt.Sprintf("document.getElementById('%s')", id))
 }
+
+// ScreenshotMismatch is returned by MatchScreenshot when a page differs
+// from its baseline
+type ScreenshotMismatch struct {
+	Name string
+	// Ratio is the fraction of pixels which differ, 1 if the sizes differ
+	Ratio float64
+	// Actual and Diff are the paths of the new screenshot and of an image
+	// marking the pixels which differ in red. There is no Diff if the sizes
+	// differ.
+	Actual, Diff string
+}
+
+func (e *ScreenshotMismatch) Error() string {
+	if e.Diff == "" {
+		return fmt.Sprintf("screenshot %s differs in size from its baseline, see %s", e.Name, e.Actual)
+	}
+	return fmt.Sprintf("screenshot %s differs from its baseline in %.2f%% of pixels, see %s",
+		e.Name, e.Ratio*100, e.Diff)
+}
+
+// MatchScreenshot compares a screenshot of the page with the baseline
+// <name>.png in the Browser's Screenshots folder. A missing baseline is
+// created from the screenshot, as is every baseline when Update is set. If
+// more than Threshold of the pixels differ a *ScreenshotMismatch is
+// returned, and the screenshot and a diff are saved next to the baseline.
+func (p *BrowserPage) MatchScreenshot(name string) error {
+	actual, err := p.Screenshot()
+	if err != nil {
+		return err
+	}
+
+	dir := p.browser.Screenshots
+	baselinePath := filepath.Join(dir, name+".png")
+
+	baseline, err := os.ReadFile(baselinePath)
+	if p.browser.Update || errors.Is(err, fs.ErrNotExist) {
+		return utils.OutputFile(baselinePath, actual)
+	}
+	if err != nil {
+		return err
+	}
+
+	ratio, diff, err := diffImages(baseline, actual)
+	if err != nil {
+		return fmt.Errorf("screenshot %s: %w", name, err)
+	}
+	if ratio <= p.browser.Threshold {
+		return nil
+	}
+
+	mismatch := &ScreenshotMismatch{
+		Name:   name,
+		Ratio:  ratio,
+		Actual: filepath.Join(dir, name+".actual.png"),
+	}
+	if err := utils.OutputFile(mismatch.Actual, actual); err != nil {
+		return err
+	}
+	if diff != nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, diff); err != nil {
+			return err
+		}
+		mismatch.Diff = filepath.Join(dir, name+".diff.png")
+		if err := utils.OutputFile(mismatch.Diff, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return mismatch
+}
+
+// diffImages returns the fraction of pixels in which two PNGs differ, and
+// an image of the first with those pixels in red. Images of different
+// sizes differ entirely and have no diff image.
+func diffImages(a, b []byte) (float64, image.Image, error) {
+	imgA, err := png.Decode(bytes.NewReader(a))
+	if err != nil {
+		return 0, nil, err
+	}
+	imgB, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	bounds := imgA.Bounds()
+	if bounds.Size() != imgB.Bounds().Size() {
+		return 1, nil, nil
+	}
+
+	diff := image.NewRGBA(bounds)
+	offset := imgB.Bounds().Min.Sub(bounds.Min)
+	red := color.RGBA{R: 255, A: 255}
+
+	differing := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pa := imgA.At(x, y)
+			if pixelsDiffer(pa, imgB.At(x+offset.X, y+offset.Y)) {
+				differing++
+				diff.Set(x, y, red)
+				continue
+			}
+			// fade the matching pixels so that the differences stand out
+			r, g, bl, _ := pa.RGBA()
+			diff.Set(x, y, color.RGBA{
+				R: uint8(r>>8/4 + 191),
+				G: uint8(g>>8/4 + 191),
+				B: uint8(bl>>8/4 + 191),
+				A: 255,
+			})
+		}
+	}
+
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return 0, diff, nil
+	}
+	return float64(differing) / float64(total), diff, nil
+}
+
+// pixelsDiffer reports whether any channel of two colours differs by more
+// than anti-aliasing and compression usually account for
+func pixelsDiffer(a, b color.Color) bool {
+	const tolerance = 16 << 8
+
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	for _, d := range [][2]uint32{{ar, br}, {ag, bg}, {ab, bb}, {aa, ba}} {
+		if d[0] > d[1]+tolerance || d[1] > d[0]+tolerance {
+			return true
+		}
+	}
+	return false
+}
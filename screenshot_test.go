@@ -0,0 +1,83 @@
+package celeritas
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testPNG(t *testing.T, w, h int, paint func(x, y int) color.Color) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, paint(x, y))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiffImages(t *testing.T) {
+	white := func(int, int) color.Color { return color.White }
+	baseline := testPNG(t, 10, 10, white)
+
+	ratio, diff, err := diffImages(baseline, testPNG(t, 10, 10, func(x, y int) color.Color {
+		// a change too slight to see
+		return color.RGBA{R: 250, G: 250, B: 250, A: 255}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ratio != 0 || diff == nil {
+		t.Errorf("expected slight changes to be ignored, got %v of pixels differing", ratio)
+	}
+
+	ratio, diff, err = diffImages(baseline, testPNG(t, 10, 10, func(x, y int) color.Color {
+		if y == 0 {
+			return color.Black
+		}
+		return color.White
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ratio != 0.1 {
+		t.Errorf("expected a tenth of the pixels to differ, got %v", ratio)
+	}
+	if got := diff.At(0, 0); got != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("expected a differing pixel to be red in the diff, got %v", got)
+	}
+
+	ratio, diff, err = diffImages(baseline, testPNG(t, 10, 5, white))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ratio != 1 || diff != nil {
+		t.Errorf("expected images of different sizes to differ entirely without a diff, got %v and %v", ratio, diff)
+	}
+
+	if _, _, err := diffImages(baseline, []byte("not a png")); err == nil {
+		t.Error("expected an error for an invalid image")
+	}
+}
+
+func TestScreenshotMismatch_Error(t *testing.T) {
+	err := &ScreenshotMismatch{Name: "home", Ratio: 1, Actual: "home.actual.png"}
+	if !strings.Contains(err.Error(), "home.actual.png") {
+		t.Errorf("expected a mismatch without a diff to point to the screenshot, got %q", err)
+	}
+
+	err.Diff = "home.diff.png"
+	if !strings.Contains(err.Error(), "home.diff.png") {
+		t.Errorf("expected a mismatch to point to the diff, got %q", err)
+	}
+}
@@ -0,0 +1,198 @@
+package celeritas
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/s-petr/celeritas/render"
+	"github.com/s-petr/celeritas/settings"
+)
+
+// CORSOptions configure the CORS middleware. They are read from the CORS_*
+// settings.
+type CORSOptions struct {
+	// AllowedOrigins may contain "*" to allow any origin
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how many seconds browsers may cache the result of a
+	// preflight request
+	MaxAge int
+}
+
+// SecurityHeaders configure the SecureHeaders middleware. They are read from
+// the settings, and a header left empty is not sent.
+type SecurityHeaders struct {
+	// HSTSMaxAge is how many seconds browsers should only use https; 0
+	// leaves Strict-Transport-Security off
+	HSTSMaxAge            int
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// CSP is the Content-Security-Policy. Every {nonce} in it is replaced by
+	// a nonce made for each request, which templates can read as CSPNonce.
+	CSP               string
+	FrameOptions      string
+	ReferrerPolicy    string
+	PermissionsPolicy string
+}
+
+func corsFromSettings(cfg settings.CORS) CORSOptions {
+	return CORSOptions{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	}
+}
+
+func securityHeadersFromSettings(cfg settings.Headers) SecurityHeaders {
+	return SecurityHeaders{
+		HSTSMaxAge:            cfg.HSTSMaxAge,
+		HSTSIncludeSubdomains: cfg.HSTSIncludeSubdomains,
+		HSTSPreload:           cfg.HSTSPreload,
+		CSP:                   cfg.CSP,
+		FrameOptions:          cfg.FrameOptions,
+		ReferrerPolicy:        cfg.ReferrerPolicy,
+		PermissionsPolicy:     cfg.PermissionsPolicy,
+	}
+}
+
+// CORS answers preflight requests and adds the Access-Control-* headers to
+// requests from the allowed origins. It does nothing unless
+// CORS_ALLOWED_ORIGINS is set.
+func (c *Celeritas) CORS(next http.Handler) http.Handler {
+	opts := c.config.cors
+	if len(opts.AllowedOrigins) == 0 {
+		return next
+	}
+
+	anyOrigin := slices.Contains(opts.AllowedOrigins, "*")
+	methods := strings.Join(opts.AllowedMethods, ", ")
+	headers := strings.Join(opts.AllowedHeaders, ", ")
+	exposed := strings.Join(opts.ExposedHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+
+		listed := origin != "" && slices.ContainsFunc(opts.AllowedOrigins, func(o string) bool {
+			return strings.EqualFold(o, origin)
+		})
+		allowed := listed || (origin != "" && anyOrigin)
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if allowed {
+			// only origins listed by name may make credentialed requests;
+			// any other origin gets the wildcard, which browsers never send
+			// credentials to
+			if listed {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				if opts.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			}
+			if exposed != "" && !preflight {
+				w.Header().Set("Access-Control-Expose-Headers", exposed)
+			}
+		}
+
+		if !preflight {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			if opts.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(opts.MaxAge))
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// SecureHeaders adds the security headers configured in .env to every
+// response, making a fresh Content-Security-Policy nonce for each request
+// if the policy asks for one
+func (c *Celeritas) SecureHeaders(next http.Handler) http.Handler {
+	headers := c.config.securityHeaders
+
+	hsts := ""
+	if headers.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", headers.HSTSMaxAge)
+		if headers.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if headers.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	useNonce := strings.Contains(headers.CSP, "{nonce}")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+
+		// browsers ignore HSTS over plain http
+		if hsts != "" && (r.TLS != nil || c.Server.Secure) {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		if headers.FrameOptions != "" {
+			h.Set("X-Frame-Options", headers.FrameOptions)
+		}
+		if headers.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", headers.ReferrerPolicy)
+		}
+		if headers.PermissionsPolicy != "" {
+			h.Set("Permissions-Policy", headers.PermissionsPolicy)
+		}
+
+		if headers.CSP != "" {
+			csp := headers.CSP
+			if useNonce {
+				nonce, err := newNonce()
+				if err != nil {
+					c.ErrorLog.Println(err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+				csp = strings.ReplaceAll(csp, "{nonce}", nonce)
+				r = r.WithContext(render.WithNonce(r.Context(), nonce))
+			}
+			h.Set("Content-Security-Policy", csp)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CSPNonce returns the Content-Security-Policy nonce made for r by
+// SecureHeaders, for inline scripts and styles written outside templates
+func CSPNonce(r *http.Request) string {
+	return render.Nonce(r)
+}
+
+func newNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
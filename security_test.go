@@ -0,0 +1,42 @@
+package celeritas
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name            string
+		opts            CORSOptions
+		origin          string
+		wantOrigin      string
+		wantCredentials bool
+	}{
+		{"listed origin", CORSOptions{AllowedOrigins: []string{"https://a.test"}, AllowCredentials: true}, "https://a.test", "https://a.test", true},
+		{"unlisted origin", CORSOptions{AllowedOrigins: []string{"https://a.test"}, AllowCredentials: true}, "https://b.test", "", false},
+		{"wildcard", CORSOptions{AllowedOrigins: []string{"*"}}, "https://b.test", "*", false},
+		{"wildcard with credentials", CORSOptions{AllowedOrigins: []string{"https://a.test", "*"}, AllowCredentials: true}, "https://b.test", "*", false},
+		{"listed beside wildcard", CORSOptions{AllowedOrigins: []string{"https://a.test", "*"}, AllowCredentials: true}, "https://a.test", "https://a.test", true},
+	}
+
+	for _, tt := range tests {
+		c := newTestCeleritas()
+		c.config.cors = tt.opts
+
+		handler := c.CORS(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Origin", tt.origin)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s: expected Access-Control-Allow-Origin %q, got %q", tt.name, tt.wantOrigin, got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+			t.Errorf("%s: expected credentials %t, got %t", tt.name, tt.wantCredentials, got)
+		}
+	}
+}
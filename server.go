@@ -3,13 +3,12 @@ package celeritas
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"time"
 )
 
 func (c *Celeritas) ListenAndServe() error {
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%s", os.Getenv("PORT")),
+		Addr:         fmt.Sprintf(":%s", c.Server.Port),
 		ErrorLog:     c.ErrorLog,
 		Handler:      c.Routes,
 		IdleTimeout:  30 * time.Second,
@@ -21,6 +20,10 @@ func (c *Celeritas) ListenAndServe() error {
 		defer c.DB.Pool.Close()
 	}
 
+	if c.DB.ReadPool != nil {
+		defer c.DB.ReadPool.Close()
+	}
+
 	if redisPool != nil {
 		defer redisPool.Close()
 	}
@@ -29,9 +32,26 @@ func (c *Celeritas) ListenAndServe() error {
 		defer badgerConn.Close()
 	}
 
+	if myTieredCache != nil {
+		defer myTieredCache.Close()
+	}
+
+	if c.FileSystems != nil {
+		defer c.FileSystems.Close()
+	}
+
+	if c.Scheduler != nil {
+		c.Scheduler.Start()
+		defer func() {
+			if err := c.Scheduler.Shutdown(30 * time.Second); err != nil {
+				c.ErrorLog.Println(err)
+			}
+		}()
+	}
+
 	go c.listenRPC()
 
-	c.InfoLog.Printf("Listening on port %s", os.Getenv("PORT"))
+	c.InfoLog.Printf("Listening on port %s", c.Server.Port)
 
 	return srv.ListenAndServe()
 }
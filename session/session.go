@@ -10,6 +10,7 @@ import (
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/postgresstore"
 	"github.com/alexedwards/scs/redisstore"
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
 	"github.com/gomodule/redigo/redis"
 )
@@ -48,7 +49,9 @@ func (c *Session) InitSession() *scs.SessionManager {
 	session := scs.New()
 	session.Lifetime = time.Duration(minutes) * time.Minute
 	session.Cookie.Persist = persist
-	session.Cookie.Name = c.CookieName
+	if c.CookieName != "" {
+		session.Cookie.Name = c.CookieName
+	}
 	session.Cookie.Domain = c.CookieDomain
 	session.Cookie.Secure = secure
 	session.Cookie.SameSite = http.SameSiteLaxMode
@@ -60,6 +63,8 @@ func (c *Session) InitSession() *scs.SessionManager {
 		session.Store = mysqlstore.New(c.DBPool)
 	case "postgres", "postgresql":
 		session.Store = postgresstore.New(c.DBPool)
+	case "sqlite", "sqlite3":
+		session.Store = sqlite3store.New(c.DBPool)
 	default:
 		// cookie
 	}
@@ -1,11 +1,14 @@
 package session
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"reflect"
 	"testing"
 
 	"github.com/alexedwards/scs/v2"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func TestSession_InitSession(t *testing.T) {
@@ -49,3 +52,43 @@ func TestSession_InitSession(t *testing.T) {
 			reflect.ValueOf(sm).Type(), "and got", sessType)
 	}
 }
+
+func TestSession_DefaultCookieName(t *testing.T) {
+	c := &Session{SessionType: "cookie"}
+
+	if name := c.InitSession().Cookie.Name; name != "session" {
+		t.Errorf("expected the default cookie name session, got %q", name)
+	}
+}
+
+func TestSession_SQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:sessions?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE sessions (token TEXT PRIMARY KEY, data BLOB NOT NULL, expiry REAL NOT NULL)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Session{SessionType: "sqlite", DBPool: db}
+	sess := c.InitSession()
+
+	ctx, err := sess.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.Put(ctx, "userID", 1)
+
+	token, _, err := sess.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored int
+	if err := db.QueryRow("SELECT count(*) FROM sessions WHERE token = ?", token).Scan(&stored); err != nil || stored != 1 {
+		t.Errorf("expected the session in the sessions table, got %d (%v)", stored, err)
+	}
+}
@@ -0,0 +1,131 @@
+package settings
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every setting which could not be read or is
+// invalid
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		lines[i] = "  " + err.Error()
+	}
+	return fmt.Sprintf("invalid configuration (%d problems):\n%s", len(e.Errors), strings.Join(lines, "\n"))
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// decode fills the fields of the struct pointed to by v from the
+// environment variables named by their env tags. A field whose variables
+// are all empty gets the value of its default tag, if any. A field with an
+// unset tag instead gets that value only if none of its variables are set,
+// so that setting one empty turns the default off.
+func decode(v any) []error {
+	var errs []error
+
+	value := reflect.ValueOf(v).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+
+		tag, ok := field.Tag.Lookup("env")
+		if !ok {
+			if field.Type.Kind() == reflect.Struct {
+				errs = append(errs, decode(value.Field(i).Addr().Interface())...)
+			}
+			continue
+		}
+
+		names := strings.Split(tag, ",")
+		raw, set := "", false
+		for _, name := range names {
+			value, ok := os.LookupEnv(name)
+			set = set || ok
+			if raw = strings.TrimSpace(value); raw != "" {
+				break
+			}
+		}
+		if raw == "" {
+			raw = field.Tag.Get("default")
+		}
+		if unset, ok := field.Tag.Lookup("unset"); ok && !set {
+			raw = unset
+		}
+		if raw == "" {
+			continue
+		}
+
+		if oneof := field.Tag.Get("oneof"); oneof != "" {
+			raw = strings.ToLower(raw)
+			if !containsWord(oneof, raw) {
+				errs = append(errs, fmt.Errorf("%s: %q is not one of %s", names[0], raw, strings.ReplaceAll(oneof, " ", ", ")))
+				continue
+			}
+		}
+
+		if err := setField(value.Field(i), raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[0], err))
+		}
+	}
+
+	return errs
+}
+
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		field.SetInt(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+func containsWord(words, word string) bool {
+	for _, w := range strings.Fields(words) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFiles reads app.yml, app.yaml or app.toml from dir, then the
+// overlay for env over it, and returns the settings they hold keyed by
+// environment variable name
+func readConfigFiles(dir, env string) (map[string]string, error) {
+	values := make(map[string]string)
+
+	names := []string{"app"}
+	if env != "" {
+		names = append(names, "app."+env)
+	}
+
+	for _, name := range names {
+		for _, ext := range []string{".yml", ".yaml", ".toml"} {
+			path := filepath.Join(dir, name+ext)
+
+			data, err := os.ReadFile(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			tree := make(map[string]any)
+			if ext == ".toml" {
+				err = toml.Unmarshal(data, &tree)
+			} else {
+				err = yaml.Unmarshal(data, &tree)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+
+			flatten("", tree, values)
+		}
+	}
+
+	return values, nil
+}
+
+// flatten turns nested keys into environment variable names, so that
+//
+//	database:
+//	  type: postgres
+//
+// sets DATABASE_TYPE. Keys naming a Config field, such as upload.max_size,
+// set the variable the field is read from, here MAX_UPLOAD_SIZE. Lists
+// become comma separated values.
+func flatten(prefix string, tree map[string]any, values map[string]string) {
+	for key, value := range tree {
+		name := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		if v, ok := value.(map[string]any); ok {
+			flatten(name, v, values)
+			continue
+		}
+
+		if env, ok := fieldNames[normalize(name)]; ok {
+			name = env
+		}
+
+		switch v := value.(type) {
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[name] = strings.Join(items, ",")
+		case nil:
+			values[name] = ""
+		default:
+			values[name] = fmt.Sprint(v)
+		}
+	}
+}
+
+// fieldNames maps each Config field, as a normalized group and field name
+// such as UPLOADMAXSIZE, to the variable it is read from
+var fieldNames = func() map[string]string {
+	names := make(map[string]string)
+
+	config := reflect.TypeOf(Config{})
+	for i := 0; i < config.NumField(); i++ {
+		group := config.Field(i)
+		for j := 0; j < group.Type.NumField(); j++ {
+			field := group.Type.Field(j)
+			if env, ok := field.Tag.Lookup("env"); ok {
+				env, _, _ = strings.Cut(env, ",")
+				names[normalize(group.Name+field.Name)] = env
+			}
+		}
+	}
+
+	return names
+}()
+
+func normalize(name string) string {
+	return strings.ToUpper(strings.NewReplacer("_", "", "-", "").Replace(name))
+}
@@ -0,0 +1,285 @@
+// Package settings loads an application's configuration into a typed
+// Config. Settings come, from highest precedence to lowest, from the real
+// environment, the .env file, config/app.<APP_ENV>.yml (or .yaml or .toml)
+// and config/app.yml. A setting NAME may also be read from the file named
+// by NAME_FILE, which is how secrets are commonly mounted.
+package settings
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config holds every setting the framework reads at startup
+type Config struct {
+	App      App
+	Database Database
+	Redis    Redis
+	Cache    Cache
+	Cookie   Cookie
+	Session  Session
+	Mail     Mail
+	Upload   Upload
+	Startup  Startup
+	CORS     CORS
+	Headers  Headers
+	CSRF     CSRF
+}
+
+type App struct {
+	Name string `env:"APP_NAME"`
+	// Env selects the config/app.<Env> overlay, e.g. production
+	Env        string `env:"APP_ENV"`
+	Debug      bool   `env:"DEBUG" default:"true"`
+	Port       int    `env:"PORT"`
+	RPCPort    int    `env:"RPC_PORT"`
+	ServerName string `env:"SERVER_NAME"`
+	ServerURL  string `env:"SERVER_URL"`
+	Secure     bool   `env:"SECURE" default:"true"`
+	Renderer   string `env:"RENDERER" oneof:"go jet"`
+	// Key is the encryption key, which must be 32 characters long
+	Key string `env:"KEY"`
+}
+
+type Database struct {
+	Type string `env:"DATABASE_TYPE" oneof:"postgres postgresql pgx mysql mariadb sqlite sqlite3"`
+	Host string `env:"DATABASE_HOST"`
+	Port int    `env:"DATABASE_PORT"`
+	User string `env:"DATABASE_USER"`
+	Pass string `env:"DATABASE_PASS"`
+	// Name is the path of the database file for SQLite, relative to the
+	// application's root
+	Name    string `env:"DATABASE_NAME"`
+	SSLMode string `env:"DATABASE_SSL_MODE"`
+
+	// MaxOpenConns limits the connections of each pool, 0 meaning no
+	// limit; MaxIdleConns is how many are kept open when idle
+	MaxOpenConns    int           `env:"DATABASE_MAX_OPEN_CONNS" default:"25"`
+	MaxIdleConns    int           `env:"DATABASE_MAX_IDLE_CONNS" default:"25"`
+	ConnMaxLifetime time.Duration `env:"DATABASE_CONN_MAX_LIFETIME" default:"5m"`
+	ConnMaxIdleTime time.Duration `env:"DATABASE_CONN_MAX_IDLE_TIME" default:"5m"`
+
+	// ReadHost, if set, is a read replica. The replica's other settings
+	// default to the primary's.
+	ReadHost string `env:"DATABASE_READ_HOST"`
+	ReadPort int    `env:"DATABASE_READ_PORT"`
+	ReadUser string `env:"DATABASE_READ_USER"`
+	ReadPass string `env:"DATABASE_READ_PASS"`
+	ReadName string `env:"DATABASE_READ_NAME"`
+
+	// HealthInterval is how often the pools are checked; 0 turns the
+	// checks off
+	HealthInterval time.Duration `env:"DATABASE_HEALTH_INTERVAL" default:"30s"`
+}
+
+type Redis struct {
+	Host     string `env:"REDIS_HOST"`
+	Password string `env:"REDIS_PASSWORD"`
+	Prefix   string `env:"REDIS_PREFIX"`
+}
+
+type Cache struct {
+	Driver     string `env:"CACHE" oneof:"memory redis badger tiered"`
+	Codec      string `env:"CACHE_CODEC" oneof:"gob json msgpack"`
+	Prefix     string `env:"CACHE_PREFIX"`
+	MemorySize int    `env:"CACHE_MEMORY_SIZE" default:"10000"`
+}
+
+type Cookie struct {
+	Name string `env:"COOKIE_NAME"`
+	// Lifetime is in minutes
+	Lifetime int `env:"COOKIE_LIFETIME" default:"60"`
+	// COOKIE_PERSISTS is the name earlier versions read
+	Persist bool   `env:"COOKIE_PERSIST,COOKIE_PERSISTS"`
+	Secure  bool   `env:"COOKIE_SECURE"`
+	Domain  string `env:"COOKIE_DOMAIN"`
+}
+
+type Session struct {
+	Type string `env:"SESSION_TYPE" oneof:"cookie redis badger mysql mariadb postgres postgresql sqlite sqlite3"`
+}
+
+type Mail struct {
+	Domain         string `env:"MAIL_DOMAIN"`
+	SMTPHost       string `env:"SMTP_HOST"`
+	SMTPPort       int    `env:"SMTP_PORT"`
+	SMTPUsername   string `env:"SMTP_USERNAME"`
+	SMTPPassword   string `env:"SMTP_PASSWORD"`
+	SMTPEncryption string `env:"SMTP_ENCRYPTION"`
+	FromName       string `env:"FROM_NAME"`
+	FromAddress    string `env:"FROM_ADDRESS"`
+	API            string `env:"MAILER_API"`
+	APIKey         string `env:"MAILER_KEY"`
+	APIURL         string `env:"MAILER_URL"`
+}
+
+type Upload struct {
+	AllowedFileTypes []string `env:"ALLOWED_FILETYPES"`
+	// MaxSize is in bytes
+	MaxSize int64 `env:"MAX_UPLOAD_SIZE" default:"10485760"`
+}
+
+// CORS configures cross-origin requests, which are refused unless
+// AllowedOrigins is set
+type CORS struct {
+	// AllowedOrigins may contain "*" to allow any origin
+	AllowedOrigins   []string `env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   []string `env:"CORS_ALLOWED_METHODS" default:"GET,POST,PUT,PATCH,DELETE"`
+	AllowedHeaders   []string `env:"CORS_ALLOWED_HEADERS" default:"Accept,Authorization,Content-Type,X-CSRF-Token"`
+	ExposedHeaders   []string `env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool     `env:"CORS_ALLOW_CREDENTIALS"`
+	// MaxAge is in seconds
+	MaxAge int `env:"CORS_MAX_AGE"`
+}
+
+// Headers are the security headers sent with every response. A header set
+// empty is not sent.
+type Headers struct {
+	// HSTSMaxAge is in seconds, 0 leaving Strict-Transport-Security off
+	HSTSMaxAge            int    `env:"HSTS_MAX_AGE"`
+	HSTSIncludeSubdomains bool   `env:"HSTS_INCLUDE_SUBDOMAINS"`
+	HSTSPreload           bool   `env:"HSTS_PRELOAD"`
+	CSP                   string `env:"CONTENT_SECURITY_POLICY"`
+	FrameOptions          string `env:"X_FRAME_OPTIONS" unset:"SAMEORIGIN"`
+	ReferrerPolicy        string `env:"REFERRER_POLICY" unset:"strict-origin-when-cross-origin"`
+	PermissionsPolicy     string `env:"PERMISSIONS_POLICY"`
+}
+
+type CSRF struct {
+	// Exempt lists globs of paths which are not checked
+	Exempt         []string `env:"CSRF_EXEMPT" unset:"/api/*"`
+	CookieSameSite string   `env:"CSRF_COOKIE_SAMESITE" oneof:"strict lax none"`
+	Mode           string   `env:"CSRF_MODE" oneof:"form header"`
+	FailureView    string   `env:"CSRF_FAILURE_VIEW"`
+}
+
+// Startup controls how connecting to the database and Redis is retried
+// while the application starts
+type Startup struct {
+	Attempts int `env:"STARTUP_ATTEMPTS" default:"1"`
+	// Backoff is the wait after the first failed attempt, doubled after
+	// each further one up to MaxBackoff
+	Backoff    time.Duration `env:"STARTUP_BACKOFF" default:"1s"`
+	MaxBackoff time.Duration `env:"STARTUP_MAX_BACKOFF" default:"30s"`
+}
+
+// Load reads the settings of the application in rootPath into the process
+// environment, so that they are seen by everything reading os.Getenv, and
+// returns them as a Config. If any setting is invalid the Config is
+// returned along with a *ValidationError listing every problem.
+func Load(rootPath string) (*Config, error) {
+	if err := godotenv.Load(filepath.Join(rootPath, ".env")); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+
+	values, err := readConfigFiles(filepath.Join(rootPath, "config"), os.Getenv("APP_ENV"))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range values {
+		if _, ok := os.LookupEnv(name); !ok {
+			if err := os.Setenv(name, value); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	errs = append(errs, resolveFileSecrets()...)
+
+	cfg := &Config{}
+	errs = append(errs, decode(cfg)...)
+	errs = append(errs, cfg.validate()...)
+
+	if len(errs) > 0 {
+		return cfg, &ValidationError{Errors: errs}
+	}
+	return cfg, nil
+}
+
+// resolveFileSecrets sets each NAME which is empty but has a NAME_FILE to
+// the contents of that file
+func resolveFileSecrets() []error {
+	var errs []error
+
+	for _, kv := range os.Environ() {
+		key, path, _ := strings.Cut(kv, "=")
+		name, ok := strings.CutSuffix(key, "_FILE")
+		if !ok || name == "" || path == "" || os.Getenv(name) != "" {
+			continue
+		}
+
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+
+		if err := os.Setenv(name, strings.TrimRight(string(secret), "\r\n")); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// validate checks settings which depend on each other
+func (cfg *Config) validate() []error {
+	var errs []error
+
+	if cfg.App.Key != "" && len(cfg.App.Key) != 32 {
+		errs = append(errs, fmt.Errorf("KEY: must be 32 characters long, not %d", len(cfg.App.Key)))
+	}
+
+	usesRedis := cfg.Cache.Driver == "redis" || cfg.Cache.Driver == "tiered" || cfg.Session.Type == "redis"
+	if usesRedis && cfg.Redis.Host == "" {
+		errs = append(errs, fmt.Errorf("REDIS_HOST: required when CACHE or SESSION_TYPE is redis"))
+	}
+
+	switch cfg.Session.Type {
+	case "mysql", "mariadb", "postgres", "postgresql", "sqlite", "sqlite3":
+		if cfg.Database.Type == "" {
+			errs = append(errs, fmt.Errorf("DATABASE_TYPE: required when SESSION_TYPE is %s", cfg.Session.Type))
+		}
+	}
+
+	switch cfg.Database.Type {
+	case "":
+	case "sqlite", "sqlite3":
+		if cfg.Database.Name == "" {
+			errs = append(errs, fmt.Errorf("DATABASE_NAME: required when DATABASE_TYPE is %s", cfg.Database.Type))
+		}
+		if cfg.Database.ReadHost != "" {
+			errs = append(errs, fmt.Errorf("DATABASE_READ_HOST: not supported when DATABASE_TYPE is %s", cfg.Database.Type))
+		}
+	default:
+		if cfg.Database.Host == "" {
+			errs = append(errs, fmt.Errorf("DATABASE_HOST: required when DATABASE_TYPE is set"))
+		}
+	}
+
+	if cfg.Upload.MaxSize <= 0 {
+		errs = append(errs, fmt.Errorf("MAX_UPLOAD_SIZE: must be more than 0"))
+	}
+
+	if cfg.Startup.Attempts < 1 {
+		errs = append(errs, fmt.Errorf("STARTUP_ATTEMPTS: must be at least 1"))
+	}
+
+	// credentials are only sent to origins listed by name, never to *
+	if cfg.CORS.AllowCredentials && !slices.ContainsFunc(cfg.CORS.AllowedOrigins, func(origin string) bool {
+		return origin != "*"
+	}) {
+		errs = append(errs, fmt.Errorf("CORS_ALLOW_CREDENTIALS: needs CORS_ALLOWED_ORIGINS to list origins by name, they are never sent to *"))
+	}
+
+	return errs
+}
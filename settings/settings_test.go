@@ -0,0 +1,188 @@
+package settings
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	root := newApp(t, map[string]string{".env": "APP_NAME=myapp\nDEBUG=\n"})
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.App.Name != "myapp" {
+		t.Errorf("expected APP_NAME myapp, got %q", cfg.App.Name)
+	}
+	if !cfg.App.Debug {
+		t.Error("expected an empty DEBUG to default to true")
+	}
+	if cfg.Upload.MaxSize != 10<<20 {
+		t.Errorf("expected the default upload size, got %d", cfg.Upload.MaxSize)
+	}
+	if cfg.Startup.Attempts != 1 || cfg.Startup.Backoff != time.Second {
+		t.Errorf("expected a single attempt with a 1s backoff, got %+v", cfg.Startup)
+	}
+
+	if cfg.Database.MaxOpenConns != 25 || cfg.Database.HealthInterval != 30*time.Second {
+		t.Errorf("expected 25 open connections and health checks every 30s, got %+v", cfg.Database)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	root := newApp(t, map[string]string{
+		".env": "APP_ENV=production\nREDIS_PREFIX=from-env-file\n",
+		"config/app.yml": `
+app:
+  name: from-base
+redis:
+  prefix: from-base
+  host: localhost:6379
+cache:
+  memory-size: 50
+upload:
+  allowed-filetypes: [image/png, image/jpeg]
+`,
+		"config/app.production.toml": `
+[app]
+name = "from-overlay"
+
+[cache]
+memory_size = 100
+`,
+	})
+	t.Setenv("REDIS_HOST", "from-environment:6379")
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.App.Name != "from-overlay" {
+		t.Errorf("expected the overlay to win over the base file, got %q", cfg.App.Name)
+	}
+	if cfg.Cache.MemorySize != 100 {
+		t.Errorf("expected 100 from the overlay, got %d", cfg.Cache.MemorySize)
+	}
+	if cfg.Redis.Prefix != "from-env-file" {
+		t.Errorf("expected .env to win over config files, got %q", cfg.Redis.Prefix)
+	}
+	if cfg.Redis.Host != "from-environment:6379" {
+		t.Errorf("expected the environment to win over config files, got %q", cfg.Redis.Host)
+	}
+	if strings.Join(cfg.Upload.AllowedFileTypes, ",") != "image/png,image/jpeg" {
+		t.Errorf("expected the list from the base file, got %v", cfg.Upload.AllowedFileTypes)
+	}
+}
+
+func TestLoad_FileSecrets(t *testing.T) {
+	root := newApp(t, map[string]string{"secrets/key": "abcdefghijklmnopqrstuvwxyz123456\n"})
+	if err := os.WriteFile(filepath.Join(root, ".env"), []byte("KEY_FILE="+filepath.Join(root, "secrets/key")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.App.Key != "abcdefghijklmnopqrstuvwxyz123456" {
+		t.Errorf("expected KEY to be read from KEY_FILE, got %q", cfg.App.Key)
+	}
+}
+
+func TestLoad_Validation(t *testing.T) {
+	root := newApp(t, map[string]string{".env": `
+SMTP_PORT=twenty-five
+DEBUG=maybe
+CACHE=memcached
+SESSION_TYPE=redis
+KEY=short
+DATABASE_PASS_FILE=/does/not/exist
+`})
+
+	cfg, err := Load(root)
+
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	if cfg == nil {
+		t.Fatal("expected the config to be returned with the error")
+	}
+
+	for _, setting := range []string{"SMTP_PORT", "DEBUG", "CACHE", "REDIS_HOST", "KEY", "DATABASE_PASS_FILE"} {
+		if !strings.Contains(err.Error(), setting+":") {
+			t.Errorf("expected a problem with %s in %q", setting, err)
+		}
+	}
+
+	if len(invalid.Errors) != 6 {
+		t.Errorf("expected 6 problems, got %d", len(invalid.Errors))
+	}
+}
+
+func TestLoad_SQLite(t *testing.T) {
+	root := newApp(t, map[string]string{".env": "DATABASE_TYPE=sqlite\nSESSION_TYPE=sqlite\n"})
+
+	_, err := Load(root)
+	if err == nil || !strings.Contains(err.Error(), "DATABASE_NAME:") || strings.Contains(err.Error(), "DATABASE_HOST:") {
+		t.Errorf("expected SQLite to need DATABASE_NAME and not DATABASE_HOST, got %v", err)
+	}
+
+	if err := os.Setenv("DATABASE_NAME", "data/app.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(root); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoad_Unset(t *testing.T) {
+	root := newApp(t, map[string]string{".env": "X_FRAME_OPTIONS=\nCSRF_EXEMPT=\n"})
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Headers.FrameOptions != "" || len(cfg.CSRF.Exempt) != 0 {
+		t.Errorf("expected settings set empty to stay empty, got %q and %v", cfg.Headers.FrameOptions, cfg.CSRF.Exempt)
+	}
+	if cfg.Headers.ReferrerPolicy != "strict-origin-when-cross-origin" {
+		t.Errorf("expected the default referrer policy, got %q", cfg.Headers.ReferrerPolicy)
+	}
+}
+
+func TestLoad_CORSCredentials(t *testing.T) {
+	t.Run("wildcard only", func(t *testing.T) {
+		root := newApp(t, map[string]string{".env": "CORS_ALLOWED_ORIGINS=*\nCORS_ALLOW_CREDENTIALS=true\n"})
+
+		if _, err := Load(root); err == nil || !strings.Contains(err.Error(), "CORS_ALLOW_CREDENTIALS:") {
+			t.Errorf("expected credentials for any origin to be refused, got %v", err)
+		}
+	})
+
+	// the named origin is sent credentials, any other is answered with *
+	t.Run("listed beside wildcard", func(t *testing.T) {
+		root := newApp(t, map[string]string{".env": "CORS_ALLOWED_ORIGINS=https://a.test,*\nCORS_ALLOW_CREDENTIALS=true\n"})
+
+		if _, err := Load(root); err != nil {
+			t.Errorf("expected credentials for an origin listed beside * to be allowed, got %v", err)
+		}
+	})
+}
+
+func TestLoad_SQLiteReplica(t *testing.T) {
+	root := newApp(t, map[string]string{".env": "DATABASE_TYPE=sqlite\nDATABASE_NAME=data/app.db\nDATABASE_READ_HOST=replica\n"})
+
+	if _, err := Load(root); err == nil || !strings.Contains(err.Error(), "DATABASE_READ_HOST:") {
+		t.Errorf("expected a read replica to be refused for sqlite, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
+
+// newApp writes files into a temporary application root, and makes sure
+// every setting Load may set is cleared once the test ends
+func newApp(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		for i := range kv {
+			if kv[i] == '=' {
+				before[kv[:i]] = true
+				break
+			}
+		}
+	}
+
+	t.Cleanup(func() {
+		for _, kv := range os.Environ() {
+			for i := range kv {
+				if kv[i] == '=' {
+					if !before[kv[:i]] {
+						_ = os.Unsetenv(kv[:i])
+					}
+					break
+				}
+			}
+		}
+	})
+
+	return root
+}
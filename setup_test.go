@@ -0,0 +1,27 @@
+package celeritas
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/s-petr/celeritas/cache"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
+
+// newTestCeleritas returns an application with a memory cache and loggers
+// which discard their output, without reading any settings
+func newTestCeleritas() *Celeritas {
+	memoryCache := cache.NewMemoryCache(0)
+
+	return &Celeritas{
+		InfoLog:  log.New(io.Discard, "", 0),
+		ErrorLog: log.New(io.Discard, "", 0),
+		Cache:    memoryCache,
+		Locker:   memoryCache,
+	}
+}
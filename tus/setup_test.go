@@ -0,0 +1,37 @@
+package tus
+
+import (
+	"log"
+	"os"
+	"testing"
+)
+
+var testUploadDir string
+var testDestination string
+
+func TestMain(m *testing.M) {
+	tmp, err := os.MkdirTemp("", "tus")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	testUploadDir = tmp + "/uploads"
+	testDestination = tmp + "/files"
+
+	if err := os.MkdirAll(testDestination, 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	_ = os.RemoveAll(tmp)
+	os.Exit(code)
+}
+
+func newTestHandler() *Handler {
+	return &Handler{
+		BasePath:    "/files",
+		UploadDir:   testUploadDir,
+		Destination: testDestination,
+		MaxSize:     1024,
+	}
+}
@@ -0,0 +1,413 @@
+package tus
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/s-petr/celeritas/filesystems"
+	"github.com/s-petr/celeritas/filesystems/local"
+)
+
+// Version is the tus protocol version implemented by Handler
+const Version = "1.0.0"
+
+// Extensions lists the tus extensions supported by Handler
+const Extensions = "creation,termination"
+
+// Handler implements the core protocol and the creation and termination
+// extensions of the tus resumable upload protocol (https://tus.io).
+// Chunks are appended to a file in UploadDir; once the final chunk has been
+// received the assembled file is stored in Destination on FS, or on the
+// local disk if FS is nil.
+type Handler struct {
+	BasePath         string
+	UploadDir        string
+	Destination      string
+	FS               filesystems.FS
+	MaxSize          int64
+	AllowedMimeTypes []string
+	OnComplete       func(Upload)
+	locks            sync.Map
+}
+
+// Upload describes the state of a single resumable upload
+type Upload struct {
+	ID       string            `json:"id"`
+	Length   int64             `json:"length"`
+	Offset   int64             `json:"offset"`
+	FileName string            `json:"file_name"`
+	Metadata map[string]string `json:"metadata"`
+	MimeType string            `json:"mime_type,omitempty"`
+}
+
+var errNotFound = errors.New("upload not found")
+var errInvalidType = errors.New("invalid file type")
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", Version)
+
+	if r.Method != http.MethodOptions &&
+		r.Header.Get("Tus-Resumable") != Version {
+		w.Header().Set("Tus-Version", Version)
+		http.Error(w, "unsupported tus version", http.StatusPreconditionFailed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, h.BasePath), "/")
+
+	switch {
+	case r.Method == http.MethodOptions:
+		h.options(w)
+	case r.Method == http.MethodPost && id == "":
+		h.create(w, r)
+	case r.Method == http.MethodHead && id != "":
+		h.head(w, id)
+	case r.Method == http.MethodPatch && id != "":
+		h.patch(w, r, id)
+	case r.Method == http.MethodDelete && id != "":
+		h.terminate(w, id)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
+			http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) options(w http.ResponseWriter) {
+	w.Header().Set("Tus-Version", Version)
+	w.Header().Set("Tus-Extension", Extensions)
+	if h.MaxSize > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.MaxSize, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "invalid Upload-Length header", http.StatusBadRequest)
+		return
+	}
+
+	if h.MaxSize > 0 && length > h.MaxSize {
+		http.Error(w, "upload exceeds maximum size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metadata, err := parseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, "invalid Upload-Metadata header", http.StatusBadRequest)
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fileName := id
+	if name := metadata["filename"]; name != "" {
+		if !validFileName(name) {
+			http.Error(w, "invalid filename in Upload-Metadata header", http.StatusBadRequest)
+			return
+		}
+		fileName = name
+	}
+
+	upload := Upload{
+		ID:       id,
+		Length:   length,
+		FileName: fileName,
+		Metadata: metadata,
+	}
+
+	if err := os.MkdirAll(h.UploadDir, 0755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	f, err := os.Create(h.dataPath(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	f.Close()
+
+	if err := h.saveInfo(upload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if length == 0 {
+		if err := h.finish(upload); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(h.BasePath, "/")+"/"+id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) head(w http.ResponseWriter, id string) {
+	upload, err := h.loadInfo(id)
+	if err != nil {
+		h.notFound(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "invalid Content-Type header", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	lock := h.lock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	upload, err := h.loadInfo(id)
+	if err != nil {
+		h.notFound(w, err)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid Upload-Offset header", http.StatusBadRequest)
+		return
+	}
+
+	if offset != upload.Offset {
+		http.Error(w, "Upload-Offset does not match current offset", http.StatusConflict)
+		return
+	}
+
+	f, err := os.OpenFile(h.dataPath(id), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// whatever was received before a dropped connection is kept, so the
+	// client can resume from the offset reported by HEAD
+	written, copyErr := io.Copy(f, io.LimitReader(r.Body, upload.Length-upload.Offset))
+	closeErr := f.Close()
+
+	upload.Offset += written
+	if err := h.saveInfo(upload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if copyErr != nil || closeErr != nil {
+		http.Error(w, "upload interrupted", http.StatusInternalServerError)
+		return
+	}
+
+	if upload.Offset == upload.Length {
+		if err := h.finish(upload); err != nil {
+			if errors.Is(err, errInvalidType) {
+				http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) terminate(w http.ResponseWriter, id string) {
+	lock := h.lock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := h.loadInfo(id); err != nil {
+		h.notFound(w, err)
+		return
+	}
+
+	h.cleanup(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finish moves a completed upload to its destination and removes the
+// temporary files. If storing it fails the data is kept, so that the client
+// can finish the upload by sending its final PATCH again.
+func (h *Handler) finish(upload Upload) error {
+	mimeType, err := mimetype.DetectFile(h.dataPath(upload.ID))
+	if err != nil {
+		return err
+	}
+	upload.MimeType = mimeType.String()
+
+	if len(h.AllowedMimeTypes) > 0 && !includes(h.AllowedMimeTypes, upload.MimeType) {
+		h.cleanup(upload.ID)
+		return fmt.Errorf("%w (%s)", errInvalidType, upload.MimeType)
+	}
+
+	dir := filepath.Join(h.UploadDir, upload.ID+".d")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	fileName := filepath.Join(dir, upload.FileName)
+	if err := os.Rename(h.dataPath(upload.ID), fileName); err != nil {
+		return err
+	}
+
+	fs := h.FS
+	if fs == nil {
+		fs = &local.Local{}
+	}
+
+	if err := fs.Put(fileName, h.Destination); err != nil {
+		// put the data back for the next attempt
+		if renameErr := os.Rename(fileName, h.dataPath(upload.ID)); renameErr != nil {
+			return errors.Join(err, renameErr)
+		}
+		return err
+	}
+
+	h.cleanup(upload.ID)
+
+	if h.OnComplete != nil {
+		h.OnComplete(upload)
+	}
+
+	return nil
+}
+
+func (h *Handler) cleanup(id string) {
+	_ = os.Remove(h.dataPath(id))
+	_ = os.Remove(h.infoPath(id))
+	_ = os.RemoveAll(filepath.Join(h.UploadDir, id+".d"))
+	h.locks.Delete(id)
+}
+
+func (h *Handler) notFound(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func (h *Handler) lock(id string) *sync.Mutex {
+	l, _ := h.locks.LoadOrStore(id, &sync.Mutex{})
+	return l.(*sync.Mutex)
+}
+
+func (h *Handler) dataPath(id string) string {
+	return filepath.Join(h.UploadDir, id+".bin")
+}
+
+func (h *Handler) infoPath(id string) string {
+	return filepath.Join(h.UploadDir, id+".info")
+}
+
+func (h *Handler) saveInfo(upload Upload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.infoPath(upload.ID), data, 0644)
+}
+
+func (h *Handler) loadInfo(id string) (Upload, error) {
+	var upload Upload
+
+	if !validID(id) {
+		return upload, errNotFound
+	}
+
+	data, err := os.ReadFile(h.infoPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return upload, errNotFound
+		}
+		return upload, err
+	}
+
+	err = json.Unmarshal(data, &upload)
+	return upload, err
+}
+
+// parseMetadata decodes the Upload-Metadata header, a comma separated list
+// of keys with optional base64 encoded values
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		switch len(parts) {
+		case 1:
+			metadata[parts[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			metadata[parts[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("invalid metadata pair %q", pair)
+		}
+	}
+
+	return metadata, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// validFileName reports whether name can be used as the name of a file
+// without leaving its directory
+func validFileName(name string) bool {
+	return name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+func includes(slice []string, val string) bool {
+	for _, item := range slice {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
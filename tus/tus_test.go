@@ -0,0 +1,226 @@
+package tus
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/s-petr/celeritas/filesystems/local"
+)
+
+func doRequest(h *Handler, method, target string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Tus-Resumable", Version)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func createUpload(t *testing.T, h *Handler, length, fileName string) string {
+	metadata := "filename " + base64.StdEncoding.EncodeToString([]byte(fileName))
+	w := doRequest(h, http.MethodPost, "/files", "", map[string]string{
+		"Upload-Length":   length,
+		"Upload-Metadata": metadata,
+	})
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d creating upload, got %d", http.StatusCreated, w.Code)
+	}
+
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, "/files/") {
+		t.Fatalf("unexpected Location header %q", location)
+	}
+	return location
+}
+
+func TestHandler_Options(t *testing.T) {
+	h := newTestHandler()
+
+	r := httptest.NewRequest(http.MethodOptions, "/files", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	if w.Header().Get("Tus-Extension") != Extensions {
+		t.Errorf("expected Tus-Extension %q, got %q", Extensions, w.Header().Get("Tus-Extension"))
+	}
+
+	if w.Header().Get("Tus-Max-Size") != "1024" {
+		t.Errorf("expected Tus-Max-Size 1024, got %q", w.Header().Get("Tus-Max-Size"))
+	}
+}
+
+func TestHandler_ResumeUpload(t *testing.T) {
+	h := newTestHandler()
+	location := createUpload(t, h, "11", "hello.txt")
+
+	w := doRequest(h, http.MethodPatch, location, "hello", map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": "0",
+	})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	w = doRequest(h, http.MethodHead, location, "", nil)
+	if w.Header().Get("Upload-Offset") != "5" {
+		t.Errorf("expected offset 5, got %q", w.Header().Get("Upload-Offset"))
+	}
+
+	w = doRequest(h, http.MethodPatch, location, " world", map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": "0",
+	})
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d for stale offset, got %d", http.StatusConflict, w.Code)
+	}
+
+	w = doRequest(h, http.MethodPatch, location, " world", map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": "5",
+	})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	data, err := os.ReadFile(testDestination + "/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "hello world" {
+		t.Errorf("expected assembled file to contain \"hello world\", got %q", string(data))
+	}
+
+	w = doRequest(h, http.MethodHead, location, "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected finished upload to be removed, got status %d", w.Code)
+	}
+}
+
+func TestHandler_Terminate(t *testing.T) {
+	h := newTestHandler()
+	location := createUpload(t, h, "10", "terminate.txt")
+
+	w := doRequest(h, http.MethodDelete, location, "", nil)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	w = doRequest(h, http.MethodHead, location, "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d after termination, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestHandler_Errors(t *testing.T) {
+	h := newTestHandler()
+
+	var tests = []struct {
+		name     string
+		method   string
+		target   string
+		headers  map[string]string
+		expected int
+	}{
+		{"too_large", http.MethodPost, "/files", map[string]string{"Upload-Length": "2048"}, http.StatusRequestEntityTooLarge},
+		{"missing_length", http.MethodPost, "/files", nil, http.StatusBadRequest},
+		{"wrong_version", http.MethodPost, "/files", map[string]string{"Tus-Resumable": "0.2.0"}, http.StatusPreconditionFailed},
+		{"unknown_upload", http.MethodHead, "/files/00000000000000000000000000000000", nil, http.StatusNotFound},
+		{"invalid_id", http.MethodHead, "/files/../../etc", nil, http.StatusNotFound},
+		{"parent_filename", http.MethodPost, "/files", map[string]string{"Upload-Length": "5", "Upload-Metadata": "filename Li4="}, http.StatusBadRequest},
+		{"filename_with_dir", http.MethodPost, "/files", map[string]string{"Upload-Length": "5", "Upload-Metadata": "filename ZG9jcy9hLnR4dA=="}, http.StatusBadRequest},
+	}
+
+	for _, e := range tests {
+		w := doRequest(h, e.method, e.target, "", e.headers)
+		if w.Code != e.expected {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.expected, w.Code)
+		}
+	}
+}
+
+func TestHandler_InvalidMimeType(t *testing.T) {
+	h := newTestHandler()
+	h.AllowedMimeTypes = []string{"image/png"}
+	location := createUpload(t, h, "5", "hello.txt")
+
+	w := doRequest(h, http.MethodPatch, location, "hello", map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": "0",
+	})
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status %d, got %d", http.StatusUnsupportedMediaType, w.Code)
+	}
+}
+
+// failOnceFS fails the first Put, as storage might when briefly unreachable
+type failOnceFS struct {
+	*local.Local
+	failed bool
+}
+
+func (f *failOnceFS) Put(fileName, folder string) error {
+	if !f.failed {
+		f.failed = true
+		return errors.New("storage unavailable")
+	}
+	return f.Local.Put(fileName, folder)
+}
+
+func TestHandler_RetryFinish(t *testing.T) {
+	h := newTestHandler()
+	h.FS = &failOnceFS{Local: &local.Local{}}
+	location := createUpload(t, h, "5", "retry.txt")
+
+	patch := func(body string, offset string) *httptest.ResponseRecorder {
+		return doRequest(h, http.MethodPatch, location, body, map[string]string{
+			"Content-Type":  "application/offset+octet-stream",
+			"Upload-Offset": offset,
+		})
+	}
+
+	if w := patch("hello", "0"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d when storing fails, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	if w := doRequest(h, http.MethodHead, location, "", nil); w.Header().Get("Upload-Offset") != "5" {
+		t.Fatalf("expected the upload to be kept at offset 5, got %d %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	if w := patch("", "5"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected the retried upload to finish, got %d", w.Code)
+	}
+
+	data, err := os.ReadFile(testDestination + "/retry.txt")
+	if err != nil || string(data) != "hello" {
+		t.Errorf("expected the stored file to contain \"hello\", got %q, %v", string(data), err)
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	metadata, err := parseMetadata("filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==,is_confidential")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata["filename"] != "world_domination_plan.pdf" {
+		t.Errorf("unexpected filename %q", metadata["filename"])
+	}
+
+	if _, ok := metadata["is_confidential"]; !ok {
+		t.Error("expected key without value to be present")
+	}
+}
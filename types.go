@@ -23,6 +23,10 @@ type databaseConfig struct {
 type Database struct {
 	DataType string
 	Pool     *sql.DB
+	// ReadPool, if set, is a read replica, which Reader hands out
+	ReadPool *sql.DB
+
+	health *dbHealth
 }
 
 type redisConfig struct {
@@ -6,12 +6,16 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"path"
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/s-petr/celeritas/filesystems"
+	"github.com/s-petr/celeritas/filesystems/local"
+	"github.com/s-petr/celeritas/tus"
 )
 
+// UploadFile stores the file uploaded in field in the destination folder on
+// fs, or on the FILESYSTEM_DEFAULT disk if fs is nil. Without a default disk
+// destination is a folder on the local disk, as given.
 func (c *Celeritas) UploadFile(r *http.Request, destination, field string, fs filesystems.FS) error {
 	fileName, err := c.getFileToUpload(r, field)
 	if err != nil {
@@ -19,26 +23,58 @@ func (c *Celeritas) UploadFile(r *http.Request, destination, field string, fs fi
 		return err
 	}
 
-	if fs != nil {
-		if err := fs.Put(fileName, destination); err != nil {
-			c.ErrorLog.Println(err)
-			return err
-		}
-	} else {
-		if err := os.Rename(fileName, fmt.Sprintf("%s/%s",
-			destination, path.Base(fileName))); err != nil {
-			c.ErrorLog.Println(err)
-			return err
-		}
-	}
-
 	defer func() {
 		_ = os.Remove(fileName)
 	}()
 
+	fs = c.diskOrDefault(fs)
+
+	if err := fs.Put(fileName, destination); err != nil {
+		c.ErrorLog.Println(err)
+		return err
+	}
+
 	return nil
 }
 
+// ResumableUploads returns a tus protocol handler mounted at basePath. Chunks
+// are assembled in tmp/uploads and the finished file is stored in
+// destination on fs (or on the default disk if fs is nil), subject to the
+// same MAX_UPLOAD_SIZE and ALLOWED_FILETYPES limits as UploadFile.
+// Mount it under /api/ so that tus clients are not blocked by CSRF checks:
+//
+//	app.Routes.Handle("/api/uploads/*", app.ResumableUploads("/api/uploads", "uploads", fs))
+func (c *Celeritas) ResumableUploads(basePath, destination string, fs filesystems.FS) *tus.Handler {
+	var allowedMimeTypes []string
+	for _, mimeType := range c.config.upload.allowedMimeTypes {
+		if mimeType != "" {
+			allowedMimeTypes = append(allowedMimeTypes, mimeType)
+		}
+	}
+
+	return &tus.Handler{
+		BasePath:         basePath,
+		UploadDir:        fmt.Sprintf("%s/tmp/uploads", c.RootPath),
+		Destination:      destination,
+		FS:               c.diskOrDefault(fs),
+		MaxSize:          c.config.upload.maxUploadSize,
+		AllowedMimeTypes: allowedMimeTypes,
+	}
+}
+
+// diskOrDefault returns fs, or the default disk if fs is nil. Without a
+// default disk, paths are used as given on the local disk.
+func (c *Celeritas) diskOrDefault(fs filesystems.FS) filesystems.FS {
+	if fs != nil {
+		return fs
+	}
+
+	if fs, err := c.DefaultFileSystem(); err == nil {
+		return fs
+	}
+	return &local.Local{}
+}
+
 func (c *Celeritas) getFileToUpload(r *http.Request, fieldName string) (string, error) {
 	_ = r.ParseMultipartForm(c.config.upload.maxUploadSize)
 
@@ -0,0 +1,32 @@
+package celeritas
+
+import (
+	"testing"
+
+	"github.com/s-petr/celeritas/filesystems"
+	"github.com/s-petr/celeritas/filesystems/local"
+)
+
+func TestDiskOrDefault(t *testing.T) {
+	c := newTestCeleritas()
+
+	if fs, ok := c.diskOrDefault(nil).(*local.Local); !ok || fs.Root != "" {
+		t.Errorf("expected paths as given on the local disk without a default, got %#v", c.diskOrDefault(nil))
+	}
+
+	disk := &local.Local{Root: t.TempDir()}
+	c.FileSystems = filesystems.NewRegistry()
+	c.FileSystems.Register("uploads", disk)
+	if err := c.FileSystems.SetDefault("uploads"); err != nil {
+		t.Fatal(err)
+	}
+
+	if fs := c.diskOrDefault(nil); fs != disk {
+		t.Errorf("expected the default disk, got %#v", fs)
+	}
+
+	given := &local.Local{Root: t.TempDir()}
+	if fs := c.diskOrDefault(given); fs != given {
+		t.Errorf("expected the disk given, got %#v", fs)
+	}
+}